@@ -0,0 +1,135 @@
+package crdt
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Authorizer decides whether the owner of a mutation may apply an operation
+// of the given type to a key. Every replica of a map must use the same
+// authorizers, and an authorizer must only depend on its arguments, so that
+// every replica rejects the same mutations. Owners are only authenticated by
+// maps with a keyring.
+type Authorizer interface {
+	Authorize(owner, key string, typ OperationType) error
+}
+
+// AuthorizerFunc is a function that implements Authorizer.
+type AuthorizerFunc func(owner, key string, typ OperationType) error
+
+func (f AuthorizerFunc) Authorize(owner, key string, typ OperationType) error {
+	return f(owner, key, typ)
+}
+
+// WithAuthorizer sets the authorizer that every operation of a mutation is
+// checked with before the mutation is added to the log. A mutation with an
+// operation the authorizer rejects is rejected as a whole. A checkpoint
+// restates the values of other owners, so it is checked once as a
+// CheckpointOperation on the empty key, and must only be allowed for owners
+// trusted to write checkpoints.
+func WithAuthorizer(a Authorizer) Option {
+	return func(o *ORSetMap) {
+		o.authorizer = a
+	}
+}
+
+// OwnerOnly returns an authorizer that lets only the owner named by a key
+// write it, for keys with the given prefix: with the prefix "users/", only
+// alice may write "users/alice" and the keys below "users/alice/". Other keys
+// may be written by anyone. Only the given checkpointers may write
+// checkpoints.
+func OwnerOnly(prefix string, checkpointers ...string) Authorizer {
+	return AuthorizerFunc(func(owner, key string, typ OperationType) error {
+		if typ == CheckpointOperation {
+			if owner == "" || !slices.Contains(checkpointers, owner) {
+				return fmt.Errorf("owner %q may not write checkpoints", owner)
+			}
+			return nil
+		}
+		rest, found := strings.CutPrefix(key, prefix)
+		if !found {
+			return nil
+		}
+		keyOwner, _, _ := strings.Cut(rest, "/")
+		if owner == "" || owner != keyOwner {
+			return fmt.Errorf("key %s belongs to owner %s", key, keyOwner)
+		}
+
+		return nil
+	})
+}
+
+// RolePolicy is an authorizer that lets the owners holding one of the roles
+// listed for the longest prefix of a key write it. Keys that no prefix
+// matches may be written by anyone. Only owners holding one of the
+// checkpointer roles may write checkpoints.
+type RolePolicy struct {
+	Roles         map[string][]string // owner -> roles of the owner
+	Writers       map[string][]string // key prefix -> roles that may write keys with it
+	Checkpointers []string            // roles that may write checkpoints
+}
+
+func (p RolePolicy) Authorize(owner, key string, typ OperationType) error {
+	if typ == CheckpointOperation {
+		for _, role := range p.Roles[owner] {
+			if slices.Contains(p.Checkpointers, role) {
+				return nil
+			}
+		}
+		return fmt.Errorf("owner %q has none of the roles %v that may write checkpoints", owner, p.Checkpointers)
+	}
+	writers, found := longestPrefix(p.Writers, key)
+	if !found {
+		return nil
+	}
+	for _, role := range p.Roles[owner] {
+		if slices.Contains(writers, role) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("owner %q has none of the roles %v that may write key %s", owner, writers, key)
+}
+
+// authorize checks every operation of a mutation, or the owner of a
+// checkpoint, with the authorizer. Maps with a keyring reject checkpoints
+// unless an authorizer allows them.
+func (o *ORSetMap) authorize(mu Mutation) error {
+	if len(mu.Checkpoint) > 0 {
+		if o.authorizer == nil {
+			if o.keyring != nil {
+				return fmt.Errorf("%w: checkpoint: no authorizer allows owner %q to write checkpoints", ErrUnauthorized, mu.Owner)
+			}
+			return nil
+		}
+		if err := o.authorizer.Authorize(mu.Owner, "", CheckpointOperation); err != nil {
+			return fmt.Errorf("%w: checkpoint: %w", ErrUnauthorized, err)
+		}
+		return nil
+	}
+	if o.authorizer == nil {
+		return nil
+	}
+	for i, op := range mu.Operations {
+		if err := o.authorizer.Authorize(mu.Owner, op.Key, op.Type); err != nil {
+			return fmt.Errorf("%w: operation %d: %w", ErrUnauthorized, i, err)
+		}
+	}
+
+	return nil
+}
+
+// longestPrefix returns the value of the longest prefix of key in m.
+func longestPrefix[V any](m map[string]V, key string) (V, bool) {
+	var value V
+	longest := -1
+	for prefix, v := range m {
+		if len(prefix) > longest && strings.HasPrefix(key, prefix) {
+			value = v
+			longest = len(prefix)
+		}
+	}
+
+	return value, longest >= 0
+}
@@ -0,0 +1,311 @@
+package crdt
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestAuthorizers(t *testing.T) {
+	roles := RolePolicy{
+		Roles: map[string][]string{
+			"alice": {"admin"},
+			"bob":   {"editor"},
+		},
+		Writers: map[string][]string{
+			"doc/":        {"admin", "editor"},
+			"doc/locked/": {"admin"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		authorizer Authorizer
+		owner      string
+		key        string
+		allowed    bool
+	}{
+		{name: "Own key", authorizer: OwnerOnly("users/"), owner: "alice", key: "users/alice", allowed: true},
+		{name: "Below own key", authorizer: OwnerOnly("users/"), owner: "alice", key: "users/alice/name", allowed: true},
+		{name: "Key of another owner", authorizer: OwnerOnly("users/"), owner: "bob", key: "users/alice/name"},
+		{name: "Owner prefix of another owner", authorizer: OwnerOnly("users/"), owner: "al", key: "users/alice"},
+		{name: "No owner", authorizer: OwnerOnly("users/"), owner: "", key: "users/"},
+		{name: "Key without owner", authorizer: OwnerOnly("users/"), owner: "bob", key: "shared", allowed: true},
+		{name: "Role", authorizer: roles, owner: "bob", key: "doc/title", allowed: true},
+		{name: "Missing role", authorizer: roles, owner: "carol", key: "doc/title"},
+		{name: "Longest prefix", authorizer: roles, owner: "bob", key: "doc/locked/title"},
+		{name: "Longest prefix role", authorizer: roles, owner: "alice", key: "doc/locked/title", allowed: true},
+		{name: "Unrestricted key", authorizer: roles, owner: "carol", key: "notes", allowed: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, typ := range []OperationType{AddOperation, RemoveOperation} {
+				err := tc.authorizer.Authorize(tc.owner, tc.key, typ)
+				if tc.allowed {
+					assert.NoError(t, err)
+				} else {
+					assert.Error(t, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeCheckpoints(t *testing.T) {
+	roles := RolePolicy{
+		Roles:         map[string][]string{"alice": {"admin"}, "bob": {"editor"}},
+		Writers:       map[string][]string{"doc/": {"admin", "editor"}},
+		Checkpointers: []string{"admin"},
+	}
+
+	tests := []struct {
+		name       string
+		authorizer Authorizer
+		owner      string
+		allowed    bool
+	}{
+		{name: "Owner only without checkpointers", authorizer: OwnerOnly("users/"), owner: "alice"},
+		{name: "Owner only checkpointer", authorizer: OwnerOnly("users/", "alice"), owner: "alice", allowed: true},
+		{name: "Owner only other owner", authorizer: OwnerOnly("users/", "alice"), owner: "bob"},
+		{name: "Owner only no owner", authorizer: OwnerOnly("users/", ""), owner: ""},
+		{name: "Checkpointer role", authorizer: roles, owner: "alice", allowed: true},
+		{name: "Writer role", authorizer: roles, owner: "bob"},
+		{name: "No role", authorizer: roles, owner: "carol"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.authorizer.Authorize(tc.owner, "", CheckpointOperation)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeForgedCheckpoint(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("a"), WithAuthorizer(OwnerOnly("users/"))))
+	forged := Mutation{
+		Origin:     Tag{ReplicaID: "evil", Sequence: 2},
+		Checkpoint: Version{"evil": 1},
+		Operations: []*Operation{{
+			Type:  AddOperation,
+			Key:   "users/alice",
+			Value: scalar.New("pwned"),
+			Tags:  Tags{{ReplicaID: "evil", Sequence: 1}: true},
+		}},
+	}
+
+	// rejected even when the version it claims is acknowledged
+	o.Acknowledge("evil", Version{"evil": 1})
+	err := o.ImportLog([]Mutation{forged})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Nil(t, o.Get("users/alice"))
+	assert.Empty(t, o.Version())
+	assert.Empty(t, o.Heads())
+
+	// nor may a map write checkpoints itself
+	b := must(NewORSetMap(WithReplicaID("b"), WithAuthorizer(OwnerOnly("users/"))))
+	must(b.Add("shared", scalar.New("b")))
+	_, err = b.Checkpoint()
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestAuthorizeLocal(t *testing.T) {
+	_, alice := newKey(1)
+	o := must(NewORSetMap(WithReplicaID("a"), WithSigner("alice", alice), WithAuthorizer(OwnerOnly("users/"))))
+
+	_, err := o.Add("users/bob", scalar.New("alice"))
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = o.Batch(func(tx *Tx) error {
+		tx.Add("users/alice", scalar.New("alice"))
+		tx.Remove("users/bob")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Empty(t, o.Heads())
+
+	// the rejected mutations gave their sequence back
+	hash := must(o.Add("users/alice", scalar.New("alice")))
+	mu, _ := o.Mutation(hash)
+	assert.Equal(t, Tag{ReplicaID: "a", Sequence: 1}, mu.Origin)
+}
+
+// unauthorized returns the hashes of the mutations that err reports as
+// unauthorized.
+func unauthorized(err error) map[string]bool {
+	hashes := make(map[string]bool)
+	joined, _ := err.(interface{ Unwrap() []error })
+	if joined == nil {
+		return hashes
+	}
+	for _, err := range joined.Unwrap() {
+		var mutationErr *MutationError
+		if errors.As(err, &mutationErr) && errors.Is(err, ErrUnauthorized) {
+			hashes[mutationErr.Hash] = true
+		}
+	}
+
+	return hashes
+}
+
+func TestAuthorizeConvergence(t *testing.T) {
+	keyring := make(StaticKeyring)
+	policy := RolePolicy{
+		Roles: map[string][]string{
+			"alice": {"admin"},
+			"bob":   {"editor"},
+			"carol": {"viewer"},
+		},
+		Writers: map[string][]string{
+			"doc/":    {"admin", "editor"},
+			"config/": {"admin"},
+		},
+	}
+
+	// the writers do not enforce the policy themselves
+	var writers []*ORSetMap
+	for i, owner := range []string{"alice", "bob", "carol"} {
+		pub, key := newKey(byte(i + 1))
+		keyring[owner] = pub
+		writers = append(writers, must(NewORSetMap(WithReplicaID(owner), WithSigner(owner, key))))
+	}
+	alice, bob, carol := writers[0], writers[1], writers[2]
+	must(alice.Add("config/mode", scalar.New("strict")))
+	must(bob.Add("doc/title", scalar.New("bob")))
+	exchange(t, writers...)
+	must(bob.Add("notes", scalar.New("bob")))
+	forbidden := []string{
+		must(bob.Add("config/mode", scalar.New("lax"))),
+		must(carol.Remove("doc/title")),
+	}
+	// carol's next edit builds on the rejected one and is never applied
+	must(carol.Add("notes", scalar.New("carol")))
+	must(alice.Add("doc/body", scalar.New("alice")))
+
+	var log []Mutation
+	for _, w := range writers {
+		log = append(log, must(w.ExportLog())...)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	var replicas []*ORSetMap
+	for i := 0; i < 5; i++ {
+		replica := must(NewORSetMap(WithKeyring(keyring), WithAuthorizer(policy)))
+		shuffled := append([]Mutation(nil), log...)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		rejected := make(map[string]bool)
+		for _, mu := range shuffled {
+			for hash := range unauthorized(replica.ImportLog([]Mutation{mu})) {
+				rejected[hash] = true
+			}
+		}
+		assert.Equal(t, map[string]bool{forbidden[0]: true, forbidden[1]: true}, rejected, "replica %d", i)
+		replicas = append(replicas, replica)
+	}
+
+	for i, replica := range replicas {
+		assert.Equal(t, map[string]Value{
+			"config/mode": scalar.New("strict"),
+			"doc/title":   scalar.New("bob"),
+			"doc/body":    scalar.New("alice"),
+			"notes":       scalar.New("bob"),
+		}, replica.List(), "replica %d", i)
+		assert.Equal(t, replicas[0].Heads(), replica.Heads(), "replica %d", i)
+		assert.Len(t, replica.PendingMutations(), 1, "replica %d", i)
+	}
+}
+
+func TestAuthorizeCheckpointConvergence(t *testing.T) {
+	keyring := make(StaticKeyring)
+	policy := RolePolicy{
+		Roles: map[string][]string{
+			"alice": {"admin"},
+			"bob":   {"editor"},
+			"carol": {"viewer"},
+		},
+		Writers: map[string][]string{
+			"doc/": {"admin", "editor"},
+		},
+		Checkpointers: []string{"admin"},
+	}
+
+	var writers []*ORSetMap
+	keys := make(map[string]ed25519.PrivateKey)
+	for i, owner := range []string{"alice", "bob", "carol"} {
+		pub, key := newKey(byte(i + 1))
+		keyring[owner] = pub
+		keys[owner] = key
+		writers = append(writers, must(NewORSetMap(WithReplicaID(owner), WithSigner(owner, key))))
+	}
+	alice, bob := writers[0], writers[1]
+	must(alice.Add("doc/title", scalar.New("alice")))
+	must(bob.Add("doc/body", scalar.New("bob")))
+	exchange(t, writers...)
+	checkpoint := must(alice.Checkpoint())
+	require.NotEmpty(t, checkpoint)
+	must(bob.Add("doc/title", scalar.New("bob")))
+
+	// checkpoints by owners without the checkpointer role that claim no more
+	// than was written, but give bob's last write another value
+	var log []Mutation
+	var forbidden []string
+	for _, owner := range []string{"bob", "carol"} {
+		forged := must(SignMutation(Mutation{
+			Parents:    alice.Heads(),
+			Origin:     Tag{ReplicaID: owner, Sequence: 3},
+			Checkpoint: Version{"alice": 1, "bob": 2},
+			Operations: []*Operation{{
+				Type:  AddOperation,
+				Key:   "doc/title",
+				Value: scalar.New("pwned"),
+				Tags:  Tags{{ReplicaID: "bob", Sequence: 2}: true},
+			}},
+		}, owner, keys[owner]))
+		forbidden = append(forbidden, must(HashMutation(forged)))
+		log = append(log, forged)
+	}
+	for _, w := range writers {
+		log = append(log, must(w.ExportLog())...)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	var replicas []*ORSetMap
+	for i := 0; i < 5; i++ {
+		replica := must(NewORSetMap(WithKeyring(keyring), WithAuthorizer(policy)))
+		// as sync does, so the checkpoints are accepted
+		for _, w := range writers {
+			replica.Acknowledge(w.ReplicaID(), w.Version())
+		}
+		shuffled := append([]Mutation(nil), log...)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		rejected := make(map[string]bool)
+		for _, mu := range shuffled {
+			for hash := range unauthorized(replica.ImportLog([]Mutation{mu})) {
+				rejected[hash] = true
+			}
+		}
+		assert.Equal(t, map[string]bool{forbidden[0]: true, forbidden[1]: true}, rejected, "replica %d", i)
+		replicas = append(replicas, replica)
+	}
+
+	for i, replica := range replicas {
+		assert.Equal(t, map[string]Value{
+			"doc/title": scalar.New("bob"),
+			"doc/body":  scalar.New("bob"),
+		}, replica.List(), "replica %d", i)
+		assert.Equal(t, Version{"alice": 2, "bob": 2}, replica.Version(), "replica %d", i)
+		assert.Equal(t, replicas[0].Heads(), replica.Heads(), "replica %d", i)
+	}
+}
@@ -0,0 +1,426 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/dominikbraun/graph"
+)
+
+// Checkpoint folds the causally stable mutations of the log into a single
+// checkpoint mutation and returns its hash. The checkpoint carries the live
+// tags of the folded mutations and names the folded mutations that remain
+// parents of the rest of the log as its parents. Replicas that apply it drop
+// the mutations it covers as well, and replicas that never saw them are
+// brought up to date by it. Mutations the map was restored from count as
+// folded already. The checkpoint has an origin like local writes, so versions
+// cover it as well. It returns an empty hash if no writes became stable since
+// the last checkpoint, or local writes are not stable yet.
+func (o *ORSetMap) Checkpoint() (string, error) {
+	o.mu.Lock()
+	defer o.unlock()
+
+	stable := o.stableVersion()
+	if ordering := Compare(stable, o.checkpoint); len(stable) == 0 || ordering == Before || ordering == Equal {
+		return "", nil
+	}
+	// replicas that apply the checkpoint count the local mutations before its
+	// origin as applied, so they must all be folded into it
+	if stable[o.replicaID] < o.version[o.replicaID] {
+		return "", nil
+	}
+
+	// the stable mutations are a prefix of the causal order; the rest of the
+	// log names some of them as parents
+	var prefix, stored []string
+	var folded, mutations []Mutation
+	writes := false
+	named := make(map[string]bool)
+	restored := make(map[string]bool)
+	for _, hash := range o.order {
+		mu, err := o.store.Get(hash)
+		if errors.Is(err, ErrNotFound) {
+			// folded into the snapshot the map was restored from
+			restored[hash] = true
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to retrieve mutation with hash %s: %w", hash, err)
+		}
+		stored = append(stored, hash)
+		mutations = append(mutations, mu)
+		if coveredBy(mu, stable) {
+			prefix = append(prefix, hash)
+			folded = append(folded, mu)
+			writes = writes || len(mu.Checkpoint) == 0
+			continue
+		}
+		for _, parent := range mu.Parents {
+			named[parent] = true
+		}
+	}
+	for _, p := range o.pending {
+		for _, parent := range p.mutation.Parents {
+			named[parent] = true
+		}
+	}
+	if !writes {
+		// only checkpoints became stable
+		return "", nil
+	}
+
+	elements := restoredElements(o.elements, restored, stored, mutations, stable)
+	inner := make(map[string]bool)
+	for i, mu := range folded {
+		if len(mu.Checkpoint) > 0 {
+			applyCheckpoint(elements, prefix[i], mu, nil)
+		} else {
+			applyOperations(elements, prefix[i], mu)
+		}
+		for _, parent := range mu.Parents {
+			inner[parent] = true
+		}
+	}
+
+	var parents []string
+	for _, hash := range prefix {
+		if named[hash] || !inner[hash] {
+			parents = append(parents, hash)
+		}
+	}
+	for hash := range restored {
+		if _, head := o.heads[hash]; named[hash] || head {
+			parents = append(parents, hash)
+		}
+	}
+	for hash := range o.aliases {
+		if named[hash] {
+			parents = append(parents, hash)
+		}
+	}
+	slices.Sort(parents)
+
+	var ops []*Operation
+	for _, key := range sortedKeys(elements) {
+		tags := make([]Tag, 0, len(elements[key].Tags))
+		for tag, value := range elements[key].Tags {
+			if !value.Tombstone {
+				tags = append(tags, tag)
+			}
+		}
+		slices.SortFunc(tags, compareTags)
+		for _, tag := range tags {
+			detail := elements[key].Tags[tag]
+			ops = append(ops, &Operation{
+				Type:      AddOperation,
+				Key:       key,
+				Value:     detail.Value,
+				Tags:      Tags{tag: true},
+				Time:      detail.Time,
+				Timestamp: detail.Timestamp,
+				Owner:     detail.Owner,
+			})
+		}
+	}
+
+	cp, err := o.sign(Mutation{
+		Operations: ops,
+		Parents:    parents,
+		Origin:     o.nextOrigin(),
+		Checkpoint: stable,
+	})
+	if err != nil {
+		o.sequence--
+		return "", err
+	}
+	hash, err := o.appendMutation(cp)
+	if err != nil {
+		o.sequence--
+		return "", fmt.Errorf("failed to append checkpoint: %w", err)
+	}
+
+	return hash, nil
+}
+
+// restoredElements returns the tags of the elements that the restored
+// mutations added and the version covers, live unless the restored mutations
+// removed them. Tags the stored mutations removed since are live again, so
+// that applying the stored mutations the version covers removes them once
+// more.
+func restoredElements(elements map[string]*KeyValue, restored map[string]bool, stored []string, mutations []Mutation, v Version) map[string]*KeyValue {
+	seeded := make(map[string]*KeyValue)
+	if len(restored) == 0 {
+		return seeded
+	}
+	for key, elem := range elements {
+		for tag, detail := range elem.Tags {
+			if !restored[detail.Hash] || !v.Contains(tag) {
+				continue
+			}
+			if seeded[key] == nil {
+				seeded[key] = &KeyValue{Tags: map[Tag]*ValueDetail{}}
+			}
+			live := *detail
+			live.Tombstone = false
+			seeded[key].set(tag, &live)
+		}
+	}
+
+	removed := cloneElements(seeded)
+	for i, mu := range mutations {
+		if len(mu.Checkpoint) == 0 {
+			applyOperations(removed, stored[i], mu)
+		}
+	}
+	for key, elem := range seeded {
+		for tag := range elem.Tags {
+			if elements[key].Tags[tag].Tombstone && !removed[key].Tags[tag].Tombstone {
+				elem.drop(tag)
+			}
+		}
+		if len(elem.Tags) == 0 {
+			delete(seeded, key)
+		}
+	}
+
+	return seeded
+}
+
+// applyCheckpoint applies a checkpoint to the elements. Tags the checkpoint
+// covers but does not carry were removed by the mutations it folds and are
+// dropped. Carried tags are only added if the given version does not cover
+// them; otherwise the elements already reflect them. Added values are
+// attributed to the checkpoint with the given hash.
+func applyCheckpoint(elements map[string]*KeyValue, hash string, cp Mutation, seen Version) {
+	carried := make(map[string]Tags)
+	for _, op := range cp.Operations {
+		if carried[op.Key] == nil {
+			carried[op.Key] = make(Tags)
+		}
+		for tag := range op.Tags {
+			carried[op.Key][tag] = true
+		}
+	}
+
+	for key, elem := range elements {
+		for tag := range elem.Tags {
+			if cp.Checkpoint.Contains(tag) && !carried[key][tag] {
+				elem.drop(tag)
+			}
+		}
+		if len(elem.Tags) == 0 {
+			delete(elements, key)
+		}
+	}
+
+	for _, op := range cp.Operations {
+		for tag := range op.Tags {
+			if seen.Contains(tag) {
+				continue
+			}
+			elem, exists := elements[op.Key]
+			if !exists {
+				elem = &KeyValue{Tags: map[Tag]*ValueDetail{}}
+				elements[op.Key] = elem
+			}
+			if _, exists := elem.Tags[tag]; !exists {
+				elem.set(tag, &ValueDetail{
+					Value:     op.Value,
+					Owner:     op.Owner,
+					Hash:      hash,
+					Time:      op.Time.Round(0).UTC(),
+					Timestamp: op.Timestamp,
+				})
+			}
+		}
+	}
+}
+
+// checkCheckpoint rejects a checkpoint that covers mutations neither the map
+// nor a replica that acknowledged a version to it has applied. Applying it
+// would fold the mutations it claims to cover when they arrive.
+func (o *ORSetMap) checkCheckpoint(mu Mutation) error {
+	for _, replica := range sortedKeys(mu.Checkpoint) {
+		seq := mu.Checkpoint[replica]
+		if seq <= o.version[replica] {
+			continue
+		}
+		acknowledged := false
+		for _, ack := range o.acks {
+			if seq <= ack[replica] {
+				acknowledged = true
+				break
+			}
+		}
+		if !acknowledged {
+			return fmt.Errorf("%w: checkpoint covers sequence %d of replica %s, which was neither applied nor acknowledged", ErrInvalidOperation, seq, replica)
+		}
+	}
+
+	return nil
+}
+
+// coveredBy reports whether the mutation is folded by a checkpoint of the
+// version. A checkpoint is only folded once the version covers its origin as
+// well, so that every replica applied it and none of them names it as a
+// parent any more.
+func coveredBy(mu Mutation, v Version) bool {
+	if len(mu.Checkpoint) > 0 {
+		ordering := Compare(mu.Checkpoint, v)
+		return (ordering == Before || ordering == Equal) && v.Contains(mu.Origin)
+	}
+
+	return mu.Origin.ReplicaID != "" && v.Contains(mu.Origin)
+}
+
+// folded reports whether the mutation was folded into the checkpoints the map
+// applied, or is older than them.
+func (o *ORSetMap) folded(mu Mutation) bool {
+	if len(mu.Checkpoint) > 0 {
+		return coveredBy(mu, o.checkpoint)
+	}
+
+	return coveredBy(mu, o.version)
+}
+
+// adopt takes folded mutations into the log as aliases: applied mutations
+// without content that other mutations can name as parents. It returns the
+// parked mutations that have no missing parents left as a result.
+func (o *ORSetMap) adopt(hashes []string) []string {
+	var released []string
+	for _, hash := range hashes {
+		if o.mutations[hash] {
+			continue
+		}
+		if p, exists := o.pending[hash]; exists {
+			for _, parent := range p.mutation.Parents {
+				_ = o.log.RemoveEdge(hash, parent)
+				o.unwait(parent, hash)
+			}
+			delete(o.pending, hash)
+			o.discarded = append(o.discarded, hash)
+		} else if err := o.log.AddVertex(hash); err != nil && !errors.Is(err, graph.ErrVertexAlreadyExists) {
+			continue
+		}
+		for _, child := range o.waiting[hash] {
+			_ = o.log.AddEdge(child, hash)
+		}
+
+		o.mutations[hash] = true
+		o.aliases[hash] = struct{}{}
+		released = append(released, o.release(hash)...)
+	}
+
+	return released
+}
+
+// forget takes a folded mutation that is not applied out of the log.
+func (o *ORSetMap) forget(hash string, mu Mutation) {
+	for _, parent := range mu.Parents {
+		_ = o.log.RemoveEdge(hash, parent)
+		o.unwait(parent, hash)
+	}
+	for _, child := range o.waiting[hash] {
+		_ = o.log.RemoveEdge(child, hash)
+	}
+	_ = o.log.RemoveVertex(hash)
+
+	delete(o.mutations, hash)
+	delete(o.pending, hash)
+	o.discarded = append(o.discarded, hash)
+}
+
+// unwait takes the mutation off the list of mutations waiting for parent.
+func (o *ORSetMap) unwait(parent, hash string) {
+	waiting := slices.DeleteFunc(o.waiting[parent], func(child string) bool {
+		return child == hash
+	})
+	if len(waiting) == 0 {
+		delete(o.waiting, parent)
+		return
+	}
+	o.waiting[parent] = waiting
+}
+
+// fold takes the mutations covered by the applied checkpoint out of the log
+// and the store. Those that the remaining mutations name as parents are kept
+// as aliases.
+func (o *ORSetMap) fold(checkpoint string, v Version) error {
+	covered := make(map[string]Mutation)
+	order := make([]string, 0, len(o.order))
+	named := make(map[string]bool)
+	for _, hash := range o.order {
+		mu, err := o.store.Get(hash)
+		if errors.Is(err, ErrNotFound) {
+			order = append(order, hash)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to retrieve mutation with hash %s: %w", hash, err)
+		}
+		if hash != checkpoint && coveredBy(mu, v) {
+			covered[hash] = mu
+			continue
+		}
+		order = append(order, hash)
+		for _, parent := range mu.Parents {
+			named[parent] = true
+		}
+	}
+	parked := make(map[string]Mutation)
+	for hash, p := range o.pending {
+		if coveredBy(p.mutation, v) {
+			parked[hash] = p.mutation
+			continue
+		}
+		for _, parent := range p.mutation.Parents {
+			named[parent] = true
+		}
+	}
+
+	for hash, mu := range covered {
+		for _, parent := range mu.Parents {
+			_ = o.log.RemoveEdge(hash, parent)
+		}
+	}
+	for hash := range covered {
+		delete(o.heads, hash)
+		if named[hash] {
+			o.aliases[hash] = struct{}{}
+			continue
+		}
+		_ = o.log.RemoveVertex(hash)
+		delete(o.mutations, hash)
+	}
+	for hash := range o.aliases {
+		if _, exists := covered[hash]; !exists && !named[hash] {
+			_ = o.log.RemoveVertex(hash)
+			delete(o.mutations, hash)
+			delete(o.aliases, hash)
+		}
+	}
+	o.order = order
+
+	var released []string
+	for _, hash := range sortedKeys(parked) {
+		if named[hash] {
+			released = append(released, o.adopt([]string{hash})...)
+		} else {
+			o.forget(hash, parked[hash])
+		}
+	}
+
+	discarded := o.discarded
+	for hash := range covered {
+		discarded = append(discarded, hash)
+	}
+	if err := o.store.Delete(discarded); err != nil {
+		return fmt.Errorf("failed to delete folded mutations: %w", err)
+	}
+	o.discarded = nil
+
+	o.drain(released)
+
+	return nil
+}
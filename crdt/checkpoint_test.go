@@ -0,0 +1,230 @@
+package crdt
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestCheckpointBoundsLog(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	exchange(t, a, b)
+
+	for i := 0; i < 1_000; i++ {
+		must(a.Add(fmt.Sprintf("key%d", i%10), scalar.New(int64(i))))
+	}
+	must(b.Add("b", scalar.New("b")))
+	must(b.Remove("key0"))
+
+	// nothing is stable before b acknowledged a's edits
+	assert.Empty(t, must(a.Checkpoint()))
+
+	exchange(t, a, b)
+	want := a.List()
+	hash := must(a.Checkpoint())
+	require.NotEmpty(t, hash)
+	assert.Equal(t, []string{hash}, a.Heads())
+	assert.Equal(t, []string{hash}, hashLog(must(a.ExportLog())))
+	// the heads of a and b the checkpoint names as parents stay as aliases
+	assert.Len(t, a.aliases, 2)
+	assert.Equal(t, 3, must(a.log.Order()))
+	assert.Equal(t, want, a.List())
+	assert.Empty(t, must(a.Checkpoint()), "nothing new is stable")
+
+	// b folds its log into the same checkpoint
+	must(a.Add("key1", scalar.New("after")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	assert.Equal(t, a.Heads(), b.Heads())
+	assert.Equal(t, a.List(), b.List())
+	assert.Len(t, b.order, 2)
+	assert.Equal(t, 4, must(b.log.Order()))
+	assert.Equal(t, Complete, b.State())
+}
+
+func TestCheckpointUpdatesReplicas(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	exchange(t, a, b)
+
+	must(a.Add("removed", scalar.New("a")))
+	must(a.Add("kept", scalar.New("a")))
+	early := must(a.ExportLog())
+
+	// behind has seen the early mutations only, fresh has seen nothing
+	behind := must(NewORSetMap(WithReplicaID("behind")))
+	require.NoError(t, behind.ImportLog(early))
+	fresh := must(NewORSetMap(WithReplicaID("fresh")))
+
+	must(a.Remove("removed"))
+	editConcurrently(t, a, b, 100)
+	exchange(t, a, b)
+	must(a.Checkpoint())
+
+	// b adds concurrently with the checkpoint, naming the folded mutations
+	must(b.Add("concurrent", scalar.New("b")))
+	exchange(t, a, b)
+	assert.Equal(t, a.List(), b.List())
+	assert.NotContains(t, a.List(), "removed")
+
+	for _, replica := range []*ORSetMap{behind, fresh} {
+		replica.Acknowledge(a.ReplicaID(), a.Version())
+		require.NoError(t, replica.ImportLog(must(a.ExportLog())), "replica "+replica.ReplicaID())
+		assert.Equal(t, a.Heads(), replica.Heads(), "replica "+replica.ReplicaID())
+		assert.Equal(t, a.List(), replica.List(), "replica "+replica.ReplicaID())
+		assert.Equal(t, Complete, replica.State(), "replica "+replica.ReplicaID())
+		assert.Len(t, replica.order, len(a.order), "replica "+replica.ReplicaID())
+
+		// folded mutations that arrive late are ignored
+		require.NoError(t, replica.ImportLog(early), "replica "+replica.ReplicaID())
+		assert.Equal(t, a.Heads(), replica.Heads(), "replica "+replica.ReplicaID())
+		assert.Equal(t, a.List(), replica.List(), "replica "+replica.ReplicaID())
+	}
+}
+
+func TestCheckpointStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	store := must(OpenFileStore(path))
+	o := must(NewORSetMap(WithReplicaID("a"), WithStore(store)))
+	for i := 0; i < 100; i++ {
+		must(o.Add(fmt.Sprintf("key%d", i%3), scalar.New(int64(i))))
+	}
+	must(o.Remove("key0"))
+	before := must(os.Stat(path)).Size()
+
+	// alone, all of a replica's own mutations are stable
+	hash := must(o.Checkpoint())
+	require.NotEmpty(t, hash)
+	must(o.Add("key0", scalar.New("after")))
+	assert.Less(t, must(os.Stat(path)).Size(), before/10)
+	want := o.List()
+	heads := o.Heads()
+	require.NoError(t, store.Close())
+
+	store = must(OpenFileStore(path))
+	defer store.Close()
+	reopened := must(NewORSetMap(WithReplicaID("a"), WithStore(store)))
+	assert.Equal(t, want, reopened.List())
+	assert.Equal(t, heads, reopened.Heads())
+	assert.Equal(t, Complete, reopened.State())
+	assert.Equal(t, []string{hash, heads[0]}, reopened.order)
+}
+
+func TestCheckpointAheadOfVersion(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	must(b.Add("title", scalar.New("b")))
+	must(b.Add("body", scalar.New("b")))
+
+	// a checkpoint claiming mutations of b that a neither applied nor heard
+	// b acknowledge
+	err := a.ImportLog([]Mutation{{Origin: Tag{ReplicaID: "c", Sequence: 1}, Checkpoint: Version{"b": 1 << 40}}})
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	assert.Empty(t, a.Version())
+
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	assert.Equal(t, b.List(), a.List())
+	assert.Equal(t, Complete, a.State())
+
+	// once b acknowledges more than the checkpoint claims, it is accepted
+	a.Acknowledge("b", Version{"b": 3})
+	cp := Mutation{Origin: Tag{ReplicaID: "c", Sequence: 4}, Checkpoint: Version{"b": 3}, Parents: a.Heads()}
+	assert.NoError(t, a.ImportLog([]Mutation{cp}))
+	assert.Equal(t, Version{"b": 3, "c": 4}, a.Version())
+}
+
+func TestCheckpointConcurrent(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	exchange(t, a, b)
+	must(a.Add("a", scalar.New("a")))
+	exchange(t, a, b)
+
+	// b hears a acknowledge its write, a does not hear back, so b checkpoints
+	// more than a does
+	must(b.Add("b", scalar.New("b")))
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	b.Acknowledge(a.ReplicaID(), a.Version())
+	require.NotEmpty(t, must(a.Checkpoint()))
+	require.NotEmpty(t, must(b.Checkpoint()))
+	must(a.Add("a", scalar.New("after")))
+	must(b.Remove("b"))
+	exchange(t, a, b)
+	assert.Equal(t, a.Heads(), b.Heads())
+	assert.Equal(t, a.List(), b.List())
+	assert.Equal(t, map[string]Value{"a": scalar.New("after")}, a.List())
+	assert.Empty(t, a.MissingParents())
+	assert.Empty(t, b.MissingParents())
+
+	// a replica that joins later is brought up to date by both
+	c := must(NewORSetMap(WithReplicaID("c")))
+	c.Acknowledge(a.ReplicaID(), a.Version())
+	require.NoError(t, c.ImportLog(must(a.ExportLog())))
+	assert.Equal(t, a.Heads(), c.Heads())
+	assert.Equal(t, a.List(), c.List())
+	assert.Equal(t, Complete, c.State())
+
+	// the next checkpoint folds both
+	hash := must(a.Checkpoint())
+	require.NotEmpty(t, hash)
+	must(b.Add("b", scalar.New("again")))
+	exchange(t, a, b, c)
+	for _, replica := range []*ORSetMap{b, c} {
+		assert.Equal(t, a.Heads(), replica.Heads(), "replica "+replica.ReplicaID())
+		assert.Equal(t, a.List(), replica.List(), "replica "+replica.ReplicaID())
+		assert.Empty(t, replica.MissingParents(), "replica "+replica.ReplicaID())
+	}
+}
+
+func TestCheckpointConvergence(t *testing.T) {
+	pull := func(dst, src *ORSetMap) {
+		dst.Acknowledge(src.ReplicaID(), src.Version())
+		require.NoError(t, dst.ImportLog(must(src.ExportLog())))
+	}
+
+	for seed := int64(0); seed < 100; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		var replicas []*ORSetMap
+		for i := 0; i < 3; i++ {
+			replicas = append(replicas, must(NewORSetMap(WithReplicaID(fmt.Sprintf("r%d", i)))))
+		}
+		exchange(t, replicas...)
+
+		// every replica writes, syncs with one other and checkpoints at random
+		for step := 0; step < 100; step++ {
+			replica := replicas[rng.Intn(len(replicas))]
+			key := fmt.Sprintf("key%d", rng.Intn(4))
+			switch rng.Intn(5) {
+			case 0, 1:
+				must(replica.Add(key, scalar.New(int64(step))))
+			case 2:
+				must(replica.Remove(key))
+			case 3:
+				pull(replica, replicas[rng.Intn(len(replicas))])
+			case 4:
+				must(replica.Checkpoint())
+			}
+		}
+
+		for round := 0; round < 2; round++ {
+			for _, dst := range replicas {
+				for _, src := range replicas {
+					if dst != src {
+						pull(dst, src)
+					}
+				}
+			}
+		}
+		for _, replica := range replicas[1:] {
+			assert.Equal(t, replicas[0].Heads(), replica.Heads(), "seed %d replica %s", seed, replica.ReplicaID())
+			assert.Equal(t, replicas[0].List(), replica.List(), "seed %d replica %s", seed, replica.ReplicaID())
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package crdt
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Timestamp is a hybrid logical clock timestamp. It stays close to the wall
+// clock of the replica that minted it, but orders after every timestamp the
+// replica observed before, even if wall clocks are skewed or jump backwards,
+// as long as they are skewed by no more than the maximum clock skew.
+// Timestamps are ordered by wall time first and logical counter second.
+type Timestamp struct {
+	Wall    int64  // wall clock time in nanoseconds since the Unix epoch
+	Logical uint32 // orders timestamps with the same wall time
+}
+
+// IsZero reports whether t is the zero timestamp, which operations that were
+// not stamped carry.
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+// Compare returns -1 if t orders before other, 1 if it orders after and 0 if
+// they are equal.
+func (t Timestamp) Compare(other Timestamp) int {
+	if c := cmp.Compare(t.Wall, other.Wall); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(t.Logical, other.Logical)
+}
+
+// Time returns the wall time of the timestamp.
+func (t Timestamp) Time() time.Time {
+	return time.Unix(0, t.Wall).UTC()
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%s+%d", t.Time().Format(time.RFC3339Nano), t.Logical)
+}
+
+// DefaultMaxClockSkew is how far the timestamps of other replicas may run
+// ahead of the wall clock before the map stops following them.
+const DefaultMaxClockSkew = time.Hour
+
+// errClockExhausted is returned when the clock reached the greatest timestamp
+// and cannot advance any further.
+var errClockExhausted = errors.New("clock exhausted")
+
+// WithClock sets the wall clock the map stamps its mutations with. Without
+// WithClock time.Now is used.
+func WithClock(now func() time.Time) Option {
+	return func(o *ORSetMap) {
+		if now != nil {
+			o.clock.now = now
+		}
+	}
+}
+
+// WithMaxClockSkew sets how far the timestamps of other replicas may run ahead
+// of the wall clock. The clock follows later timestamps only up to the wall
+// clock plus d, so a replica with a broken clock cannot drag the stamps of
+// local writes into the future. Without WithMaxClockSkew DefaultMaxClockSkew
+// is used.
+func WithMaxClockSkew(d time.Duration) Option {
+	return func(o *ORSetMap) {
+		if d > 0 {
+			o.clock.maxSkew = d
+		}
+	}
+}
+
+// hybridClock is a hybrid logical clock. It is not safe for concurrent use;
+// the map guards it with its mutex.
+type hybridClock struct {
+	now     func() time.Time
+	maxSkew time.Duration
+	last    Timestamp
+}
+
+// tick returns a timestamp greater than every timestamp the clock returned or
+// observed before.
+func (c *hybridClock) tick() (Timestamp, error) {
+	wall := c.now().UnixNano()
+	if wall > c.last.Wall {
+		c.last = Timestamp{Wall: wall}
+	} else if err := c.advance(); err != nil {
+		return Timestamp{}, err
+	}
+
+	return c.last, nil
+}
+
+// observe advances the clock past a timestamp minted by another replica.
+// Timestamps more than the maximum skew ahead of the wall clock only advance
+// it up to that bound.
+func (c *hybridClock) observe(t Timestamp) {
+	wall := c.now().UnixNano()
+	limit := int64(math.MaxInt64)
+	if wall <= math.MaxInt64-int64(c.maxSkew) {
+		limit = wall + int64(c.maxSkew)
+	}
+	if t.Wall > limit {
+		t = Timestamp{Wall: limit}
+	}
+	if t.Compare(c.last) <= 0 {
+		return
+	}
+	c.last = t
+}
+
+// advance increments the logical counter, moving on to the next wall time
+// once it is exhausted. It refuses to wrap around past the greatest
+// timestamp.
+func (c *hybridClock) advance() error {
+	switch {
+	case c.last.Logical < math.MaxUint32:
+		c.last.Logical++
+	case c.last.Wall < math.MaxInt64:
+		c.last = Timestamp{Wall: c.last.Wall + 1}
+	default:
+		return errClockExhausted
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package crdt
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// manualClock is a wall clock that only moves when set.
+type manualClock struct {
+	t time.Time
+}
+
+func (c *manualClock) now() time.Time {
+	return c.t
+}
+
+// stamp returns the timestamp of the mutation with the given hash.
+func stamp(t *testing.T, o *ORSetMap, hash string) Timestamp {
+	t.Helper()
+
+	mu, exists := o.Mutation(hash)
+	require.True(t, exists)
+
+	return mu.Operations[0].Timestamp
+}
+
+func TestTimestampCompare(t *testing.T) {
+	tests := []struct {
+		a, b Timestamp
+		want int
+	}{
+		{a: Timestamp{Wall: 1}, b: Timestamp{Wall: 2}, want: -1},
+		{a: Timestamp{Wall: 2}, b: Timestamp{Wall: 1, Logical: 9}, want: 1},
+		{a: Timestamp{Wall: 1, Logical: 1}, b: Timestamp{Wall: 1, Logical: 2}, want: -1},
+		{a: Timestamp{Wall: 1, Logical: 2}, b: Timestamp{Wall: 1, Logical: 2}, want: 0},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, tc.a.Compare(tc.b), "%v %v", tc.a, tc.b)
+		assert.Equal(t, -tc.want, tc.b.Compare(tc.a), "%v %v", tc.b, tc.a)
+	}
+}
+
+func TestClockTick(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		walls []time.Duration // wall clock offsets from t0 at every tick
+		want  []Timestamp
+	}{
+		{
+			name:  "Advancing",
+			walls: []time.Duration{0, time.Second, 2 * time.Second},
+			want: []Timestamp{
+				{Wall: t0.UnixNano()},
+				{Wall: t0.Add(time.Second).UnixNano()},
+				{Wall: t0.Add(2 * time.Second).UnixNano()},
+			},
+		},
+		{
+			name:  "Standing still",
+			walls: []time.Duration{0, 0, 0},
+			want: []Timestamp{
+				{Wall: t0.UnixNano()},
+				{Wall: t0.UnixNano(), Logical: 1},
+				{Wall: t0.UnixNano(), Logical: 2},
+			},
+		},
+		{
+			name:  "Jumping backwards",
+			walls: []time.Duration{time.Hour, 0, time.Minute, 2 * time.Hour},
+			want: []Timestamp{
+				{Wall: t0.Add(time.Hour).UnixNano()},
+				{Wall: t0.Add(time.Hour).UnixNano(), Logical: 1},
+				{Wall: t0.Add(time.Hour).UnixNano(), Logical: 2},
+				{Wall: t0.Add(2 * time.Hour).UnixNano()},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			wall := &manualClock{}
+			o := must(NewORSetMap(WithReplicaID("a"), WithClock(wall.now)))
+			var got []Timestamp
+			for _, offset := range tc.walls {
+				wall.t = t0.Add(offset)
+				got = append(got, stamp(t, o, must(o.Add("k", scalar.New(offset.String())))))
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// a's wall clock runs an hour ahead of b's
+	ahead := &manualClock{t: t0.Add(time.Hour)}
+	behind := &manualClock{t: t0}
+	a := must(NewORSetMap(WithReplicaID("a"), WithClock(ahead.now), WithResolver(LastWriterWins)))
+	b := must(NewORSetMap(WithReplicaID("b"), WithClock(behind.now), WithResolver(LastWriterWins)))
+
+	first := must(a.Add("k", scalar.New("a")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+
+	// b's write follows a's although b's wall clock is behind
+	behind.t = t0.Add(time.Minute)
+	second := must(b.Add("k", scalar.New("b")))
+	assert.Equal(t, Timestamp{Wall: t0.Add(time.Hour).UnixNano(), Logical: 1}, stamp(t, b, second))
+	assert.Equal(t, 1, stamp(t, b, second).Compare(stamp(t, a, first)))
+
+	// a's write after observing b's follows it as well, even once a's wall
+	// clock jumped back
+	ahead.t = t0
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	third := must(a.Add("k", scalar.New("a again")))
+	assert.Equal(t, Timestamp{Wall: t0.Add(time.Hour).UnixNano(), Logical: 2}, stamp(t, a, third))
+
+	exchange(t, a, b)
+	assert.Equal(t, a.List(), b.List())
+	assert.Equal(t, scalar.New("a again"), b.Get("k"))
+}
+
+func TestClockLastWriterWins(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// b's and c's wall clocks are an hour behind a's
+	ahead := &manualClock{t: t0.Add(time.Hour)}
+	behind := &manualClock{t: t0}
+	a := must(NewORSetMap(WithReplicaID("a"), WithClock(ahead.now), WithResolver(LastWriterWins)))
+	b := must(NewORSetMap(WithReplicaID("b"), WithClock(behind.now), WithResolver(LastWriterWins)))
+	c := must(NewORSetMap(WithReplicaID("c"), WithClock(behind.now), WithResolver(LastWriterWins)))
+
+	must(a.Add("unrelated", scalar.New("a")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+
+	// concurrent writes of the same key: b's is stamped after everything it
+	// observed, c's only carries c's wall clock but has the greater tag
+	must(b.Add("k", scalar.New("b")))
+	for i := 0; i < 3; i++ {
+		must(c.Add("other", scalar.New(int64(i))))
+	}
+	must(c.Add("k", scalar.New("c")))
+	exchange(t, a, b, c)
+
+	for _, replica := range []*ORSetMap{a, b, c} {
+		values := replica.GetAll("k")
+		require.Len(t, values, 2, "replica "+replica.ReplicaID())
+		assert.Equal(t, scalar.New("c"), values[0].Value, "replica "+replica.ReplicaID())
+		assert.Equal(t, scalar.New("b"), replica.Get("k"), "replica "+replica.ReplicaID())
+	}
+}
+
+func TestClockRestart(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wall := &manualClock{t: t0}
+	store := NewMemoryStore()
+	o := must(NewORSetMap(WithReplicaID("a"), WithStore(store), WithClock(wall.now)))
+	last := stamp(t, o, must(o.Add("k", scalar.New("before"))))
+
+	// the wall clock jumps backwards while the replica is down
+	wall.t = t0.Add(-time.Hour)
+	reopened := must(NewORSetMap(WithReplicaID("a"), WithStore(store), WithClock(wall.now)))
+	assert.Equal(t, 1, stamp(t, reopened, must(reopened.Add("k", scalar.New("after")))).Compare(last))
+
+	restored := must(Restore(o.Snapshot(), nil, WithClock(wall.now)))
+	assert.Equal(t, 1, stamp(t, restored, must(restored.Add("k", scalar.New("after")))).Compare(last))
+}
+
+func TestClockMaxSkew(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	broken := &manualClock{t: time.Unix(0, math.MaxInt64)}
+	wall := &manualClock{t: t0}
+	a := must(NewORSetMap(WithReplicaID("a"), WithClock(broken.now)))
+	b := must(NewORSetMap(WithReplicaID("b"), WithClock(wall.now)))
+	c := must(NewORSetMap(WithReplicaID("c"), WithClock(wall.now), WithMaxClockSkew(time.Minute)))
+
+	must(a.Add("k", scalar.New("a")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	require.NoError(t, c.ImportLog(must(a.ExportLog())))
+
+	// the clocks only followed a's timestamp up to the maximum skew
+	assert.Equal(t, Timestamp{Wall: t0.Add(DefaultMaxClockSkew).UnixNano(), Logical: 1}, stamp(t, b, must(b.Add("k", scalar.New("b")))))
+	assert.Equal(t, Timestamp{Wall: t0.Add(time.Minute).UnixNano(), Logical: 1}, stamp(t, c, must(c.Add("k", scalar.New("c")))))
+}
+
+func TestClockIgnoredMutation(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wall := &manualClock{t: t0}
+	a := must(NewORSetMap(WithReplicaID("a"), WithClock(wall.now)))
+	b := must(NewORSetMap(WithReplicaID("b"), WithClock(wall.now), WithAuthorizer(OwnerOnly("users/"))))
+	must(a.Add("k", scalar.New("a")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+
+	// neither a rejected mutation nor one b already applied the sequence of
+	// advances b's clock
+	ahead := Timestamp{Wall: t0.Add(time.Minute).UnixNano()}
+	rejected := Mutation{Origin: Tag{ReplicaID: "a", Sequence: 2}, Operations: []*Operation{{
+		Type:      AddOperation,
+		Key:       "users/alice",
+		Value:     scalar.New("a"),
+		Tags:      Tags{{ReplicaID: "a", Sequence: 2}: true},
+		Timestamp: ahead,
+	}}}
+	assert.ErrorIs(t, b.ImportLog([]Mutation{rejected}), ErrUnauthorized)
+	folded := Mutation{
+		Origin: Tag{ReplicaID: "a", Sequence: 1},
+		Operations: []*Operation{{
+			Type:      AddOperation,
+			Key:       "k",
+			Value:     scalar.New("again"),
+			Tags:      Tags{{ReplicaID: "a", Sequence: 1}: true},
+			Timestamp: ahead,
+		}},
+	}
+	require.NoError(t, b.ImportLog([]Mutation{folded}))
+	assert.Equal(t, scalar.New("a"), b.Get("k"))
+
+	assert.Equal(t, Timestamp{Wall: t0.UnixNano(), Logical: 1}, stamp(t, b, must(b.Add("shared", scalar.New("b")))))
+}
+
+func TestClockExhausted(t *testing.T) {
+	wall := &manualClock{t: time.Unix(0, math.MaxInt64-1)}
+	o := must(NewORSetMap(WithReplicaID("a"), WithClock(wall.now)))
+	o.clock.last = Timestamp{Wall: math.MaxInt64 - 1, Logical: math.MaxUint32}
+
+	// the logical counter moves on to the next wall time
+	assert.Equal(t, Timestamp{Wall: math.MaxInt64}, stamp(t, o, must(o.Add("k", scalar.New("first")))))
+	o.clock.last.Logical = math.MaxUint32
+
+	// but the clock does not wrap around
+	_, err := o.Add("k", scalar.New("wrapped"))
+	assert.ErrorIs(t, err, errClockExhausted)
+	assert.Equal(t, scalar.New("first"), o.Get("k"))
+	assert.Equal(t, Version{"a": 1}, o.Version())
+}
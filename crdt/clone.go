@@ -0,0 +1,149 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Clone creates an independent replica of the map with the same history, for
+// drafts that are merged back with Merge. The clone inherits the clock,
+// resolvers, keyring, signer and authorizer of the map; the options are
+// applied after those. Without WithReplicaID the clone gets a random replica
+// ID, and it must not be given the ID of the map. The clone shares the
+// mutations with the map and puts them into its store once the map is
+// unlocked again; the rest of the state is copied.
+func (o *ORSetMap) Clone(opts ...Option) (*ORSetMap, error) {
+	c, hashes, mutations, err := o.clone(opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, hash := range hashes {
+		if err := c.store.Put(hash, mutations[i]); err != nil {
+			return nil, fmt.Errorf("failed to store mutation with hash %s: %w", hash, err)
+		}
+	}
+
+	return c, nil
+}
+
+// clone copies the state of the map into a new map, along with the stored
+// mutations that its store has to hold.
+func (o *ORSetMap) clone(opts []Option) (*ORSetMap, []string, []Mutation, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	inherited := []Option{
+		WithClock(o.clock.now),
+		WithMaxClockSkew(o.clock.maxSkew),
+		WithResolver(o.resolver),
+		WithKeyring(o.keyring),
+		WithSigner(o.owner, o.signingKey),
+		WithAuthorizer(o.authorizer),
+	}
+	for prefix, r := range o.keyResolvers {
+		inherited = append(inherited, WithKeyResolver(prefix, r))
+	}
+	c := newORSetMap(append(inherited, opts...)...)
+	if c.replicaID == o.replicaID {
+		return nil, nil, nil, fmt.Errorf("failed to clone map: replica ID %s is taken by the map", c.replicaID)
+	}
+
+	var hashes []string
+	var mutations []Mutation
+	for _, hash := range slices.Concat(o.order, sortedKeys(o.pending)) {
+		mu, err := o.store.Get(hash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to retrieve mutation with hash %s: %w", hash, err)
+		}
+		hashes = append(hashes, hash)
+		mutations = append(mutations, mu)
+	}
+	log, err := o.log.Clone()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to clone log: %w", err)
+	}
+
+	c.state = o.state
+	c.sequence = o.sequence
+	c.mutations = maps.Clone(o.mutations)
+	c.aliases = maps.Clone(o.aliases)
+	for hash, p := range o.pending {
+		c.pending[hash] = &pendingMutation{mutation: p.mutation, missing: p.missing}
+	}
+	for parent, children := range o.waiting {
+		c.waiting[parent] = slices.Clone(children)
+	}
+	c.heads = maps.Clone(o.heads)
+	c.order = slices.Clone(o.order)
+	c.version = maps.Clone(o.version)
+	c.checkpoint = maps.Clone(o.checkpoint)
+	for replica, ack := range o.acks {
+		c.acks[replica] = maps.Clone(ack)
+	}
+	// the clone knows that the map applied everything it copied
+	c.acks[o.replicaID] = maps.Clone(o.version)
+	c.elements = cloneElements(o.elements)
+	c.clock.last = o.clock.last
+	c.log = log
+
+	return c, hashes, mutations, nil
+}
+
+// Merge imports the applied mutations of other that the map lacks, and
+// records that other applied everything it has. The maps are never locked at
+// the same time, so maps can be merged into each other concurrently.
+func (o *ORSetMap) Merge(other *ORSetMap) error {
+	if other == o {
+		return nil
+	}
+
+	o.mu.Lock()
+	version, checkpoint := maps.Clone(o.version), maps.Clone(o.checkpoint)
+	o.mu.Unlock()
+
+	mutations, otherVersion, err := other.mutationsAfter(version, checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to export mutations of replica %s: %w", other.ReplicaID(), err)
+	}
+	// acknowledged first, so the checkpoints of other are accepted
+	o.Acknowledge(other.ReplicaID(), otherVersion)
+	if err := o.ImportLog(mutations); err != nil {
+		return fmt.Errorf("failed to import mutations of replica %s: %w", other.ReplicaID(), err)
+	}
+
+	return nil
+}
+
+// mutationsAfter returns the applied mutations that a map with the given
+// version and checkpoint version has not applied or folded, in causal order,
+// along with the version of the map.
+func (o *ORSetMap) mutationsAfter(version, checkpoint Version) ([]Mutation, Version, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var mutations []Mutation
+	for _, hash := range o.order {
+		mu, err := o.store.Get(hash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve mutation with hash %s: %w", hash, err)
+		}
+		covered := coveredBy(mu, version)
+		if len(mu.Checkpoint) > 0 {
+			covered = coveredBy(mu, checkpoint)
+		}
+		if covered {
+			continue
+		}
+		mutations = append(mutations, mu)
+	}
+
+	return mutations, maps.Clone(o.version), nil
+}
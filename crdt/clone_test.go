@@ -0,0 +1,163 @@
+package crdt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestClone(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("main"), WithResolver(MaxValue)))
+	must(o.Add("title", scalar.New("draft")))
+	must(o.Add("count", scalar.New(int64(1))))
+
+	draft := must(o.Clone(WithReplicaID("draft")))
+	assert.Equal(t, "draft", draft.ReplicaID())
+	assert.Equal(t, o.List(), draft.List())
+	assert.Equal(t, o.Heads(), draft.Heads())
+	assert.Equal(t, must(o.ExportLog()), must(draft.ExportLog()))
+
+	// edits on either side do not show on the other
+	must(draft.Add("title", scalar.New("final")))
+	must(o.Remove("count"))
+	assert.Equal(t, map[string]Value{"title": scalar.New("draft")}, o.List())
+	assert.Equal(t, map[string]Value{
+		"title": scalar.New("final"),
+		"count": scalar.New(int64(1)),
+	}, draft.List())
+
+	// the clone resolves conflicts like the map
+	require.NoError(t, draft.ImportLog([]Mutation{
+		concurrentWrite("x", 1, "max", scalar.New(int64(9)), time.Time{}),
+		concurrentWrite("y", 1, "max", scalar.New(int64(2)), time.Time{}),
+	}))
+	assert.Equal(t, scalar.New(int64(9)), draft.Get("max"))
+
+	_, err := o.Clone(WithReplicaID("main"))
+	assert.Error(t, err)
+	assert.NotEqual(t, o.ReplicaID(), must(o.Clone()).ReplicaID())
+}
+
+func TestClonePending(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	must(a.Add("k", scalar.New("first")))
+	must(a.Add("k", scalar.New("second")))
+	log := must(a.ExportLog())
+
+	require.ErrorIs(t, b.ImportLog(log[1:]), ErrUnknownParent)
+	clone := must(b.Clone())
+	assert.Equal(t, b.PendingMutations(), clone.PendingMutations())
+
+	// the parked mutation is applied on the clone only
+	require.NoError(t, clone.ImportLog(log[:1]))
+	assert.Equal(t, scalar.New("second"), clone.Get("k"))
+	assert.Empty(t, clone.PendingMutations())
+	assert.Nil(t, b.Get("k"))
+	assert.Len(t, b.PendingMutations(), 1)
+}
+
+// hookStore calls put before storing a mutation.
+type hookStore struct {
+	*MemoryStore
+	put func()
+}
+
+func (s *hookStore) Put(hash string, mu Mutation) error {
+	s.put()
+
+	return s.MemoryStore.Put(hash, mu)
+}
+
+func TestCloneUnlocked(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("main")))
+	must(o.Add("title", scalar.New("draft")))
+
+	// the map is not locked while the clone stores its mutations
+	store := &hookStore{MemoryStore: NewMemoryStore(), put: func() {
+		if assert.True(t, o.mu.TryLock()) {
+			o.mu.Unlock()
+		}
+	}}
+	draft := must(o.Clone(WithReplicaID("draft"), WithStore(store)))
+	assert.Equal(t, o.Heads(), draft.Heads())
+	assert.Equal(t, must(o.ExportLog()), must(draft.ExportLog()))
+}
+
+func TestMerge(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("main")))
+	must(o.Add("title", scalar.New("draft")))
+	must(o.Add("body", scalar.New("text")))
+	draft := must(o.Clone(WithReplicaID("draft")))
+
+	must(draft.Add("title", scalar.New("final")))
+	must(draft.Remove("body"))
+	must(o.Add("author", scalar.New("alice")))
+
+	// only the mutations the map lacks are imported
+	mutations, version, err := draft.mutationsAfter(o.Version(), nil)
+	require.NoError(t, err)
+	assert.Len(t, mutations, 2)
+	assert.Equal(t, draft.Version(), version)
+
+	require.NoError(t, o.Merge(draft))
+	require.NoError(t, draft.Merge(o))
+	assert.Equal(t, map[string]Value{
+		"title":  scalar.New("final"),
+		"author": scalar.New("alice"),
+	}, o.List())
+	assert.Equal(t, o.List(), draft.List())
+	assert.Equal(t, o.Heads(), draft.Heads())
+
+	// merging again imports nothing but tells the map that draft applied
+	// everything as well
+	assert.NotEqual(t, o.Version(), o.StableVersion())
+	require.NoError(t, o.Merge(draft))
+	assert.Equal(t, o.Version(), o.StableVersion())
+	assert.Equal(t, draft.Version(), draft.StableVersion())
+
+	require.NoError(t, o.Merge(o))
+}
+
+func TestMergeCheckpoint(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("main")))
+	must(o.Add("k", scalar.New("v")))
+	draft := must(o.Clone(WithReplicaID("draft")))
+	require.NoError(t, o.Merge(draft))
+	require.NoError(t, draft.Merge(o))
+
+	cp := must(o.Checkpoint())
+	require.NotEmpty(t, cp)
+	must(o.Add("after", scalar.New("v")))
+	require.NoError(t, draft.Merge(o))
+	assert.Equal(t, o.Heads(), draft.Heads())
+	assert.Equal(t, o.List(), draft.List())
+}
+
+func TestMergeConcurrent(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(a.Clone(WithReplicaID("b")))
+
+	var wg sync.WaitGroup
+	for _, pair := range [][2]*ORSetMap{{a, b}, {b, a}} {
+		wg.Add(1)
+		go func(dst, src *ORSetMap) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				must(src.Add("k", scalar.New(int64(i))))
+				assert.NoError(t, dst.Merge(src))
+			}
+		}(pair[0], pair[1])
+	}
+	wg.Wait()
+
+	require.NoError(t, a.Merge(b))
+	require.NoError(t, b.Merge(a))
+	assert.Equal(t, a.Heads(), b.Heads())
+	assert.Equal(t, a.List(), b.List())
+}
@@ -0,0 +1,93 @@
+package crdt
+
+import "maps"
+
+// Acknowledge records that the replica has applied every mutation covered by
+// the version. Acknowledgments only grow, an older version is merged into
+// the newer one. Every replica that can write to the map has to acknowledge
+// once, even with an empty version, before Compact drops anything.
+func (o *ORSetMap) Acknowledge(replicaID string, version Version) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if replicaID == o.replicaID {
+		return
+	}
+	ack, exists := o.acks[replicaID]
+	if !exists {
+		ack = make(Version)
+		o.acks[replicaID] = ack
+	}
+	for replica, seq := range version {
+		if seq > ack[replica] {
+			ack[replica] = seq
+		}
+	}
+}
+
+// StableVersion returns the version every known replica has applied. The
+// mutations it covers are causally stable: every mutation that is concurrent
+// with them has been applied by the map already. A replica is known once it
+// acknowledged a version or the map applied one of its mutations, and its
+// acknowledgment only counts once the map has applied everything it covers.
+func (o *ORSetMap) StableVersion() Version {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.stableVersion()
+}
+
+func (o *ORSetMap) stableVersion() Version {
+	for replica := range o.version {
+		if _, exists := o.acks[replica]; !exists && replica != o.replicaID {
+			// the replica may have written more that the map has not seen
+			return Version{}
+		}
+	}
+
+	stable := maps.Clone(o.version)
+	for _, ack := range o.acks {
+		if ordering := Compare(ack, o.version); ordering != Before && ordering != Equal {
+			return Version{}
+		}
+		for replica, seq := range stable {
+			if ack[replica] < seq {
+				stable[replica] = ack[replica]
+			}
+		}
+	}
+	maps.DeleteFunc(stable, func(_ string, seq uint64) bool {
+		return seq == 0
+	})
+
+	return stable
+}
+
+// Compact drops the stable tags that no longer affect the map because they
+// were removed or overwritten. Keys without tags left are dropped altogether.
+// Live concurrent values are kept for GetAll. It returns the number of
+// dropped tags.
+func (o *ORSetMap) Compact() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stable := o.stableVersion()
+	if len(stable) == 0 {
+		return 0
+	}
+
+	dropped := 0
+	for key, elem := range o.elements {
+		for tag, value := range elem.Tags {
+			if value.Tombstone && stable.Contains(tag) {
+				elem.drop(tag)
+				dropped++
+			}
+		}
+		if len(elem.Tags) == 0 {
+			delete(o.elements, key)
+		}
+	}
+
+	return dropped
+}
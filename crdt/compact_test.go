@@ -0,0 +1,124 @@
+package crdt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// exchange imports the logs of the replicas into each other and makes every
+// replica acknowledge the versions of the others.
+func exchange(t *testing.T, replicas ...*ORSetMap) {
+	t.Helper()
+
+	for _, dst := range replicas {
+		for _, src := range replicas {
+			if dst != src {
+				require.NoError(t, dst.ImportLog(must(src.ExportLog())))
+			}
+		}
+	}
+	for _, dst := range replicas {
+		for _, src := range replicas {
+			dst.Acknowledge(src.ReplicaID(), src.Version())
+		}
+	}
+}
+
+func TestCompactFrequentlyEditedKey(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	exchange(t, a, b)
+
+	for i := 0; i < 10_000; i++ {
+		must(a.Add("key", scalar.New(int64(i))))
+	}
+	must(b.Add("key", scalar.New("b")))
+	must(b.Add("other", scalar.New("b")))
+	must(b.Remove("other"))
+	assert.Len(t, a.elements["key"].Tags, 10_000)
+
+	// nothing is stable before b acknowledged a's edits
+	assert.Zero(t, a.Compact())
+	assert.Len(t, a.elements["key"].Tags, 10_000)
+
+	// all overwritten tags, and the removed one of other; b's concurrent
+	// value is kept
+	exchange(t, a, b)
+	for _, replica := range []*ORSetMap{a, b} {
+		assert.Equal(t, 10_000, replica.Compact(), "replica "+replica.ReplicaID())
+		assert.Len(t, replica.elements["key"].Tags, 2, "replica "+replica.ReplicaID())
+		assert.NotContains(t, replica.elements, "other", "replica "+replica.ReplicaID())
+		assert.Equal(t, map[string]Value{"key": scalar.New(int64(9_999))}, replica.List())
+	}
+
+	// another round of edits overwrites both values
+	for i := 0; i < 1_000; i++ {
+		must(b.Add("key", scalar.New(int64(i))))
+	}
+	exchange(t, a, b)
+	a.Compact()
+	assert.Len(t, a.elements["key"].Tags, 1)
+}
+
+func TestStableVersion(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+
+	// alone, a replica's own mutations are stable
+	must(a.Add("key", scalar.New("a")))
+	assert.Equal(t, Version{"a": 1}, a.StableVersion())
+
+	// a replica that wrote is unknown until it acknowledges
+	must(b.Add("key", scalar.New("b")))
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	assert.Empty(t, a.StableVersion())
+	a.Acknowledge("b", b.Version())
+	assert.Equal(t, Version{"b": 1}, a.StableVersion())
+
+	// an acknowledgment covering mutations a has not applied does not count
+	must(b.Add("key", scalar.New("b2")))
+	b.Acknowledge("a", a.Version())
+	a.Acknowledge("b", b.Version())
+	a.Acknowledge("b", Version{"b": 1})
+	assert.Empty(t, a.StableVersion())
+
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	assert.Equal(t, Version{"b": 2}, a.StableVersion())
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	a.Acknowledge("b", b.Version())
+	assert.Equal(t, b.Version(), a.StableVersion())
+}
+
+func TestCompactConvergence(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	c := must(NewORSetMap(WithReplicaID("c")))
+	exchange(t, a, b, c)
+
+	must(a.Add("title", scalar.New("first")))
+	must(b.Add("title", scalar.New("concurrent")))
+	exchange(t, a, b, c)
+	must(a.Add("title", scalar.New("merged")))
+	exchange(t, a, b, c)
+
+	// a drops the overwritten tags, c keeps them, and a's remove must still
+	// remove them on c
+	require.Equal(t, 2, a.Compact())
+	must(a.Remove("title"))
+	must(b.Add("body", scalar.New("body")))
+	require.NoError(t, c.ImportLog(must(a.ExportLog())))
+	require.NoError(t, c.ImportLog(must(b.ExportLog())))
+	exchange(t, a, b, c)
+
+	expected := map[string]Value{"body": scalar.New("body")}
+	for _, replica := range []*ORSetMap{a, b, c} {
+		assert.Equal(t, expected, replica.List(), "replica "+replica.ReplicaID())
+		replica.Compact()
+		assert.Equal(t, expected, replica.List(), "replica "+replica.ReplicaID())
+		assert.NotContains(t, replica.elements, "title", "replica "+replica.ReplicaID())
+	}
+}
@@ -0,0 +1,49 @@
+package crdt
+
+import (
+	"slices"
+	"time"
+)
+
+// Versioned is a live value of a key along with the write that added it.
+type Versioned struct {
+	Value     Value
+	Tag       Tag
+	Owner     string // owner of the mutation that added the value
+	Hash      string // mutation that added the value, or the checkpoint carrying it
+	Time      time.Time
+	Timestamp Timestamp
+}
+
+// GetAll returns every live value of key, greatest tag first. Adds overwrite
+// the values they observed, so more than one value means that concurrent
+// writes conflict. Get returns the first one unless a Resolver is
+// configured for the key.
+func (o *ORSetMap) GetAll(key string) []Versioned {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	elem, exists := o.elements[key]
+	if !exists {
+		return nil
+	}
+
+	return elem.versioned()
+}
+
+// Conflicts returns the keys that hold more than one live value, in sorted
+// order.
+func (o *ORSetMap) Conflicts() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	conflicts := make([]string, 0)
+	for key, elem := range o.elements {
+		if len(elem.liveTags()) > 1 {
+			conflicts = append(conflicts, key)
+		}
+	}
+	slices.Sort(conflicts)
+
+	return conflicts
+}
@@ -0,0 +1,73 @@
+package crdt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// withoutTime clears the times and timestamps of the values, which local adds
+// take from the clock.
+func withoutTime(values []Versioned) []Versioned {
+	for i := range values {
+		values[i].Time = time.Time{}
+		values[i].Timestamp = Timestamp{}
+	}
+
+	return values
+}
+
+func TestGetAll(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	first := must(a.Add("title", scalar.New("a")))
+	exchange(t, a, b)
+
+	// b's add overwrites a's, c's is concurrent with both
+	second := must(b.Add("title", scalar.New("b")))
+	concurrent := Mutation{
+		Operations: []*Operation{{
+			Type:  AddOperation,
+			Key:   "title",
+			Value: scalar.New("c"),
+			Tags:  Tags{{ReplicaID: "c", Sequence: 1}: true},
+		}},
+		Owner:  "carol",
+		Origin: Tag{ReplicaID: "c", Sequence: 1},
+	}
+	exchange(t, a, b)
+	require.NoError(t, a.ImportLog([]Mutation{concurrent}))
+	require.NoError(t, b.ImportLog([]Mutation{concurrent}))
+
+	want := []Versioned{
+		{Value: scalar.New("b"), Tag: Tag{ReplicaID: "b", Sequence: 2}, Hash: second},
+		{Value: scalar.New("c"), Tag: Tag{ReplicaID: "c", Sequence: 1}, Owner: "carol", Hash: must(HashMutation(concurrent))},
+	}
+	for _, replica := range []*ORSetMap{a, b} {
+		values := replica.GetAll("title")
+		assert.False(t, values[0].Time.IsZero(), "replica "+replica.ReplicaID())
+		assert.Equal(t, want, withoutTime(values), "replica "+replica.ReplicaID())
+		assert.Equal(t, want[0].Value, replica.Get("title"), "replica "+replica.ReplicaID())
+		assert.Equal(t, []string{"title"}, replica.Conflicts(), "replica "+replica.ReplicaID())
+	}
+	assert.NotEqual(t, first, second)
+
+	// the values survive a snapshot
+	restored := must(Restore(a.Snapshot(), nil))
+	assert.Equal(t, want, withoutTime(restored.GetAll("title")))
+
+	// an add that observed both resolves the conflict
+	merged := must(a.Add("title", scalar.New("merged")))
+	assert.Equal(t, []Versioned{
+		{Value: scalar.New("merged"), Tag: Tag{ReplicaID: "a", Sequence: 3}, Hash: merged},
+	}, withoutTime(a.GetAll("title")))
+	assert.Empty(t, a.Conflicts())
+
+	must(a.Remove("title"))
+	assert.Empty(t, a.GetAll("title"))
+	assert.Nil(t, a.GetAll("missing"))
+}
@@ -0,0 +1,72 @@
+package crdt
+
+import "fmt"
+
+// ChangeKind tells whether a key was added, removed or changed.
+type ChangeKind int
+
+const (
+	KeyAdded ChangeKind = iota
+	KeyRemoved
+	KeyChanged
+)
+
+// KeyChange describes how the value of a key differs between two states of
+// a map. Old is nil for an added key and New is nil for a removed one.
+type KeyChange struct {
+	Key  string
+	Kind ChangeKind
+	Old  Value
+	New  Value
+}
+
+// Diff returns the changes from the state of the map at fromHeads to its
+// state at toHeads, sorted by key. The states are read with At, so either
+// may be empty and either may be ahead of or concurrent with the other.
+func (o *ORSetMap) Diff(fromHeads, toHeads []string) ([]KeyChange, error) {
+	from, err := o.At(fromHeads...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map at %v: %w", fromHeads, err)
+	}
+	to, err := o.At(toHeads...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map at %v: %w", toHeads, err)
+	}
+
+	return DiffValues(from.List(), to.List()), nil
+}
+
+// DiffMaps returns the changes from the current values of one map to those
+// of another, sorted by key. The maps may be independent replicas that have
+// not synced.
+func DiffMaps(from, to *ORSetMap) []KeyChange {
+	return DiffValues(from.List(), to.List())
+}
+
+// DiffValues returns the changes from one set of values to another, as
+// returned by List, sorted by key. Values are compared with scalar.Any.Equal,
+// so a decimal that only gained trailing zeros is not changed.
+func DiffValues(from, to map[string]Value) []KeyChange {
+	keys := make(map[string]struct{}, len(from)+len(to))
+	for key := range from {
+		keys[key] = struct{}{}
+	}
+	for key := range to {
+		keys[key] = struct{}{}
+	}
+
+	changes := make([]KeyChange, 0)
+	for _, key := range sortedKeys(keys) {
+		before, after := from[key], to[key]
+		switch {
+		case before == nil:
+			changes = append(changes, KeyChange{Key: key, Kind: KeyAdded, New: after})
+		case after == nil:
+			changes = append(changes, KeyChange{Key: key, Kind: KeyRemoved, Old: before})
+		case !equalValues(before, after):
+			changes = append(changes, KeyChange{Key: key, Kind: KeyChanged, Old: before, New: after})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,111 @@
+package crdt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestDiff(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("a")))
+	first := must(o.Add("title", scalar.New("draft")))
+	must(o.Add("body", scalar.New("text")))
+	second := must(o.Add("tags", scalar.New("x")))
+	must(o.Remove("body"))
+	must(o.Add("title", scalar.New("final")))
+	third := must(o.Add("tags", scalar.New("x")))
+
+	tests := []struct {
+		name     string
+		from, to []string
+		want     []KeyChange
+	}{
+		{
+			name: "From empty",
+			to:   []string{first},
+			want: []KeyChange{{Key: "title", Kind: KeyAdded, New: scalar.New("draft")}},
+		},
+		{
+			name: "Forward",
+			from: []string{second},
+			to:   []string{third},
+			want: []KeyChange{
+				{Key: "body", Kind: KeyRemoved, Old: scalar.New("text")},
+				{Key: "title", Kind: KeyChanged, Old: scalar.New("draft"), New: scalar.New("final")},
+			},
+		},
+		{
+			name: "Backward",
+			from: []string{third},
+			to:   []string{second},
+			want: []KeyChange{
+				{Key: "body", Kind: KeyAdded, New: scalar.New("text")},
+				{Key: "title", Kind: KeyChanged, Old: scalar.New("final"), New: scalar.New("draft")},
+			},
+		},
+		{
+			name: "Same",
+			from: []string{third},
+			to:   []string{third},
+			want: []KeyChange{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := o.Diff(tc.from, tc.to)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, changes)
+		})
+	}
+
+	_, err := o.Diff([]string{"unknown"}, o.Heads())
+	assert.ErrorIs(t, err, ErrUnknownMutation)
+}
+
+func TestDiffMaps(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	must(a.Add("shared", scalar.New("a")))
+	must(a.Add("same", scalar.New(int64(1))))
+	exchange(t, a, b)
+
+	must(a.Remove("shared"))
+	must(b.Add("same", scalar.New(uint64(1))))
+	must(b.Add("new", scalar.New(true)))
+
+	assert.Equal(t, []KeyChange{
+		{Key: "new", Kind: KeyAdded, New: scalar.New(true)},
+		{Key: "same", Kind: KeyChanged, Old: scalar.New(int64(1)), New: scalar.New(uint64(1))},
+		{Key: "shared", Kind: KeyAdded, New: scalar.New("a")},
+	}, DiffMaps(a, b))
+
+	// the sync changes what a shows, except for the key a removed after b
+	// had seen it
+	before := a.Heads()
+	exchange(t, a, b)
+	assert.Empty(t, DiffMaps(a, b))
+	changes := must(a.Diff(before, a.Heads()))
+	assert.Equal(t, []KeyChange{
+		{Key: "new", Kind: KeyAdded, New: scalar.New(true)},
+		{Key: "same", Kind: KeyChanged, Old: scalar.New(int64(1)), New: scalar.New(uint64(1))},
+	}, changes)
+}
+
+func TestDiffValuesEqual(t *testing.T) {
+	// values are compared like scalar.Any.Equal, not by their encoding
+	from := map[string]Value{
+		"price": scalar.New(scalar.NewDecimal(10, 1)),
+		"count": scalar.New(int64(1)),
+	}
+	to := map[string]Value{
+		"price": scalar.NewAny(scalar.NewDecimal(100, 2)),
+		"count": scalar.New(uint64(1)),
+	}
+	assert.Equal(t, []KeyChange{
+		{Key: "count", Kind: KeyChanged, Old: scalar.New(int64(1)), New: scalar.New(uint64(1))},
+	}, DiffValues(from, to))
+}
@@ -0,0 +1,82 @@
+package crdt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"lukechampine.com/blake3"
+)
+
+// EncodedMutation is the canonical encoding of a mutation along with its
+// hash, as shipped between machines.
+type EncodedMutation struct {
+	Hash string // hex encoded BLAKE3 hash of Data
+	Data []byte // canonical encoding, see EncodeMutation
+}
+
+// EncodeMutations returns the canonical encodings of the mutations along with
+// their hashes.
+func EncodeMutations(mutations []Mutation) ([]EncodedMutation, error) {
+	encoded := make([]EncodedMutation, 0, len(mutations))
+	for i, mu := range mutations {
+		b, err := EncodeMutation(mu)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode mutation %d: %w", i, err)
+		}
+		encoded = append(encoded, EncodedMutation{Hash: fmt.Sprintf("%x", blake3.Sum256(b)), Data: b})
+	}
+
+	return encoded, nil
+}
+
+// ImportEncoded verifies the encoded mutations and imports them like
+// ImportLog. A mutation is rejected before it is added to the log if its
+// contents do not hash to its hash (ErrHashMismatch), are not a canonical
+// encoding (ErrInvalidEncoding), or differ from the contents the log holds
+// under the same hash (ErrConflictingDuplicate).
+func (o *ORSetMap) ImportEncoded(entries []EncodedMutation) error {
+	o.mu.Lock()
+	defer o.unlock()
+
+	return o.importEach(len(entries), func(i int) (string, error) {
+		mu, err := o.verifyEncoded(entries[i])
+		if err != nil {
+			return entries[i].Hash, err
+		}
+
+		return o.importMutation(mu)
+	})
+}
+
+// verifyEncoded decodes an encoded mutation after checking its hash, and
+// checks it against the mutation the log holds under that hash.
+func (o *ORSetMap) verifyEncoded(entry EncodedMutation) (Mutation, error) {
+	if sum := fmt.Sprintf("%x", blake3.Sum256(entry.Data)); sum != entry.Hash {
+		return Mutation{}, fmt.Errorf("%w: contents hash to %s", ErrHashMismatch, sum)
+	}
+	mu, err := DecodeMutation(entry.Data)
+	if err != nil {
+		return Mutation{}, err
+	}
+
+	if _, exists := o.mutations[entry.Hash]; !exists {
+		return mu, nil
+	}
+	stored, err := o.store.Get(entry.Hash)
+	if errors.Is(err, ErrNotFound) {
+		return mu, nil
+	}
+	if err != nil {
+		return Mutation{}, fmt.Errorf("failed to retrieve mutation with hash %s: %w", entry.Hash, err)
+	}
+	b, err := EncodeMutation(stored)
+	if err != nil {
+		return Mutation{}, fmt.Errorf("failed to encode mutation with hash %s: %w", entry.Hash, err)
+	}
+	if !bytes.Equal(b, entry.Data) {
+		return Mutation{}, ErrConflictingDuplicate
+	}
+
+	return mu, nil
+}
@@ -0,0 +1,96 @@
+package crdt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"lukechampine.com/blake3"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestImportEncoded(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	must(a.Add("title", scalar.New("draft")))
+	must(a.Add("title", scalar.New("final")))
+	entries := must(EncodeMutations(must(a.ExportLog())))
+	for i, hash := range hashLog(must(a.ExportLog())) {
+		assert.Equal(t, hash, entries[i].Hash)
+	}
+
+	b := must(NewORSetMap(WithReplicaID("b")))
+	require.NoError(t, b.ImportEncoded(entries))
+	assert.Equal(t, a.List(), b.List())
+	assert.Equal(t, a.Heads(), b.Heads())
+	require.NoError(t, b.ImportEncoded(entries), "importing again does nothing")
+}
+
+func TestImportEncodedRejects(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	must(a.Add("title", scalar.New("draft")))
+	must(a.Add("title", scalar.New("final")))
+	entries := must(EncodeMutations(must(a.ExportLog())))
+
+	// the second mutation with another value, still naming the first as its
+	// parent
+	tampered := bytes.Replace(entries[1].Data, []byte("final"), []byte("forgd"), 1)
+	require.NotEqual(t, entries[1].Data, tampered)
+	// a trailing byte the decoder rejects
+	trailing := append(bytes.Clone(entries[1].Data), 0)
+
+	tests := []struct {
+		name  string
+		entry EncodedMutation
+		want  error
+	}{
+		{
+			name:  "Tampered contents",
+			entry: EncodedMutation{Hash: entries[1].Hash, Data: tampered},
+			want:  ErrHashMismatch,
+		},
+		{
+			name:  "Hash of other contents",
+			entry: EncodedMutation{Hash: entries[0].Hash, Data: entries[1].Data},
+			want:  ErrHashMismatch,
+		},
+		{
+			name:  "Non-canonical encoding",
+			entry: EncodedMutation{Hash: fmt.Sprintf("%x", blake3.Sum256(trailing)), Data: trailing},
+			want:  ErrInvalidEncoding,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := must(NewORSetMap(WithReplicaID("b")))
+			err := b.ImportEncoded([]EncodedMutation{entries[0], tc.entry})
+			var mutationErr *MutationError
+			require.ErrorAs(t, err, &mutationErr)
+			assert.Equal(t, 1, mutationErr.Index)
+			assert.Equal(t, tc.entry.Hash, mutationErr.Hash)
+			assert.ErrorIs(t, err, tc.want)
+
+			// only the first mutation is in the log
+			assert.Equal(t, []string{entries[0].Hash}, hashLog(must(b.ExportLog())))
+			assert.Equal(t, scalar.New("draft"), b.Get("title"))
+		})
+	}
+}
+
+func TestImportEncodedConflictingDuplicate(t *testing.T) {
+	store := NewMemoryStore()
+	a := must(NewORSetMap(WithReplicaID("a"), WithStore(store)))
+	must(a.Add("title", scalar.New("draft")))
+	entries := must(EncodeMutations(must(a.ExportLog())))
+
+	// the store holds other contents under the hash, as after a corruption
+	corrupted := must(DecodeMutation(entries[0].Data))
+	corrupted.Operations[0].Value = scalar.New("corrupted")
+	store.mutations[entries[0].Hash] = corrupted
+
+	err := a.ImportEncoded(entries)
+	assert.ErrorIs(t, err, ErrConflictingDuplicate)
+}
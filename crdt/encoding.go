@@ -0,0 +1,807 @@
+package crdt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// EncodingVersion is the version of the canonical binary encoding produced by
+// EncodeMutation. It is the first byte of every encoded mutation.
+//
+// The encoding is a sequence of fields, each prefixed with its field number,
+// written in ascending field order and terminated by field number zero.
+// Fields holding their zero value are omitted, so new fields can be added
+// without changing the encoding, and therefore the hash, of existing
+// mutations. Parents and tags are sorted and values carry their scalar.Type.
+const EncodingVersion = 1
+
+// ErrInvalidEncoding is returned when decoding bytes that are not a canonical
+// encoding.
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// Field numbers of Mutation.
+const (
+	mutationFieldOwner = iota + 1
+	mutationFieldParents
+	mutationFieldOperations
+	mutationFieldOrigin
+	mutationFieldCheckpoint
+	mutationFieldSignature
+)
+
+// Field numbers of Operation.
+const (
+	operationFieldType = iota + 1
+	operationFieldKey
+	operationFieldValue
+	operationFieldTags
+	operationFieldTime
+	operationFieldContext
+	operationFieldTimestamp
+	operationFieldOwner
+)
+
+// EncodeMutation returns the canonical binary encoding of the mutation.
+func EncodeMutation(mu Mutation) ([]byte, error) {
+	e := &encoder{}
+	e.buf.WriteByte(EncodingVersion)
+	if err := e.mutation(mu); err != nil {
+		return nil, err
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// DecodeMutation decodes a mutation from its canonical binary encoding. Bytes
+// that EncodeMutation would not have produced are rejected.
+func DecodeMutation(b []byte) (Mutation, error) {
+	if len(b) == 0 {
+		return Mutation{}, fmt.Errorf("%w: empty input", ErrInvalidEncoding)
+	}
+	if b[0] != EncodingVersion {
+		return Mutation{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidEncoding, b[0])
+	}
+
+	d := &decoder{buf: b[1:]}
+	mu, err := d.mutation()
+	if err != nil {
+		return Mutation{}, err
+	}
+	if len(d.buf) != 0 {
+		return Mutation{}, fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, len(d.buf))
+	}
+
+	return mu, nil
+}
+
+type encoder struct {
+	buf     bytes.Buffer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf.Write(binary.AppendUvarint(e.scratch[:0], v))
+}
+
+func (e *encoder) varint(v int64) {
+	e.buf.Write(binary.AppendVarint(e.scratch[:0], v))
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.buf.Write(binary.BigEndian.AppendUint64(e.scratch[:0], v))
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) mutation(mu Mutation) error {
+	if mu.Owner != "" {
+		e.uvarint(mutationFieldOwner)
+		e.string(mu.Owner)
+	}
+
+	parents := slices.Clone(mu.Parents)
+	slices.Sort(parents)
+	parents = slices.Compact(parents)
+	if len(parents) > 0 {
+		e.uvarint(mutationFieldParents)
+		e.uvarint(uint64(len(parents)))
+		for _, parent := range parents {
+			e.string(parent)
+		}
+	}
+
+	if len(mu.Operations) > 0 {
+		e.uvarint(mutationFieldOperations)
+		e.uvarint(uint64(len(mu.Operations)))
+		for i, op := range mu.Operations {
+			if op == nil {
+				return fmt.Errorf("operation %d is nil", i)
+			}
+			if err := e.operation(op); err != nil {
+				return fmt.Errorf("failed to encode operation %d: %w", i, err)
+			}
+		}
+	}
+
+	if mu.Origin != (Tag{}) {
+		e.uvarint(mutationFieldOrigin)
+		e.tag(mu.Origin)
+	}
+
+	if checkpoint := nonZero(mu.Checkpoint); len(checkpoint) > 0 {
+		e.uvarint(mutationFieldCheckpoint)
+		e.version(checkpoint)
+	}
+
+	if len(mu.Signature) > 0 {
+		e.uvarint(mutationFieldSignature)
+		e.bytes(mu.Signature)
+	}
+
+	e.uvarint(0)
+
+	return nil
+}
+
+func (e *encoder) operation(op *Operation) error {
+	if op.Type != AddOperation {
+		e.uvarint(operationFieldType)
+		e.uvarint(uint64(op.Type))
+	}
+
+	if op.Key != "" {
+		e.uvarint(operationFieldKey)
+		e.string(op.Key)
+	}
+
+	if op.Value != nil {
+		e.uvarint(operationFieldValue)
+		if err := e.value(op.Value); err != nil {
+			return err
+		}
+	}
+
+	if len(op.Tags) > 0 {
+		tags := make([]Tag, 0, len(op.Tags))
+		for tag := range op.Tags {
+			tags = append(tags, tag)
+		}
+		slices.SortFunc(tags, compareTags)
+
+		e.uvarint(operationFieldTags)
+		e.uvarint(uint64(len(tags)))
+		for _, tag := range tags {
+			e.tag(tag)
+		}
+	}
+
+	if !op.Time.IsZero() {
+		e.uvarint(operationFieldTime)
+		e.time(op.Time)
+	}
+
+	if context := nonZero(op.Context); len(context) > 0 {
+		e.uvarint(operationFieldContext)
+		e.version(context)
+	}
+
+	if !op.Timestamp.IsZero() {
+		e.uvarint(operationFieldTimestamp)
+		e.timestamp(op.Timestamp)
+	}
+
+	if op.Owner != "" {
+		e.uvarint(operationFieldOwner)
+		e.string(op.Owner)
+	}
+
+	e.uvarint(0)
+
+	return nil
+}
+
+// version encodes the entries of a version sorted by replica ID. Zero
+// entries must have been dropped.
+func (e *encoder) version(v Version) {
+	replicas := sortedKeys(v)
+	e.uvarint(uint64(len(replicas)))
+	for _, replica := range replicas {
+		e.tag(Tag{ReplicaID: replica, Sequence: v[replica]})
+	}
+}
+
+// nonZero returns the version without its zero entries.
+func nonZero(v Version) Version {
+	for _, seq := range v {
+		if seq == 0 {
+			v = maps.Clone(v)
+			maps.DeleteFunc(v, func(_ string, seq uint64) bool {
+				return seq == 0
+			})
+			break
+		}
+	}
+
+	return v
+}
+
+// time encodes a non-zero time as seconds and nanoseconds since the Unix
+// epoch.
+func (e *encoder) time(t time.Time) {
+	e.varint(t.Unix())
+	e.uvarint(uint64(t.Nanosecond()))
+}
+
+// timestamp encodes a non-zero timestamp as its wall time and logical
+// counter.
+func (e *encoder) timestamp(t Timestamp) {
+	e.varint(t.Wall)
+	e.uvarint(uint64(t.Logical))
+}
+
+// decimal encodes a decimal as its scale, the sign of its coefficient and the
+// big-endian magnitude of its coefficient.
+func (e *encoder) decimal(d scalar.DecimalValue) {
+	e.buf.WriteByte(d.Scale)
+	if d.Coefficient == nil {
+		e.buf.WriteByte(0)
+		e.bytes(nil)
+		return
+	}
+	if d.Coefficient.Sign() < 0 {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+	e.bytes(d.Coefficient.Bytes())
+}
+
+func (e *encoder) tag(tag Tag) {
+	e.string(tag.ReplicaID)
+	e.uvarint(tag.Sequence)
+}
+
+func (e *encoder) value(v Value) error {
+	t := v.Type()
+	e.uvarint(uint64(t))
+
+	switch t {
+	case scalar.String:
+		s, _ := v.String()
+		e.string(s)
+	case scalar.Int64:
+		i, _ := v.Int64()
+		e.uint64(uint64(i))
+	case scalar.Uint64:
+		u, _ := v.Uint64()
+		e.uint64(u)
+	case scalar.Float64:
+		f, _ := v.Float64()
+		e.uint64(math.Float64bits(f))
+	case scalar.ByteSlice:
+		b, _ := v.ByteSlice()
+		e.bytes(b)
+	case scalar.Bool:
+		b, _ := v.Bool()
+		if b {
+			e.buf.WriteByte(1)
+		} else {
+			e.buf.WriteByte(0)
+		}
+	case scalar.Timestamp:
+		ts, _ := v.Timestamp()
+		e.varint(ts.Unix())
+		e.uvarint(uint64(ts.Nanosecond()))
+	case scalar.Decimal:
+		d, _ := v.Decimal()
+		e.decimal(d)
+	case scalar.UUID:
+		u, _ := v.UUID()
+		e.bytes(u[:])
+	case scalar.Null:
+	default:
+		return fmt.Errorf("unsupported value type %d", t)
+	}
+
+	return nil
+}
+
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: malformed uvarint", ErrInvalidEncoding)
+	}
+	// reject overlong encodings, e.g. 0x80 0x00 for zero
+	if n != len(binary.AppendUvarint(nil, v)) {
+		return 0, fmt.Errorf("%w: non-minimal uvarint", ErrInvalidEncoding)
+	}
+	d.buf = d.buf[n:]
+
+	return v, nil
+}
+
+func (d *decoder) varint() (int64, error) {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: malformed varint", ErrInvalidEncoding)
+	}
+	if n != len(binary.AppendVarint(nil, v)) {
+		return 0, fmt.Errorf("%w: non-minimal varint", ErrInvalidEncoding)
+	}
+	d.buf = d.buf[n:]
+
+	return v, nil
+}
+
+func (d *decoder) uint64() (uint64, error) {
+	if len(d.buf) < 8 {
+		return 0, fmt.Errorf("%w: unexpected end of input", ErrInvalidEncoding)
+	}
+	v := binary.BigEndian.Uint64(d.buf)
+	d.buf = d.buf[8:]
+
+	return v, nil
+}
+
+func (d *decoder) byte() (byte, error) {
+	if len(d.buf) < 1 {
+		return 0, fmt.Errorf("%w: unexpected end of input", ErrInvalidEncoding)
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+
+	return b, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.buf)) < n {
+		return nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidEncoding)
+	}
+	b := make([]byte, n)
+	copy(b, d.buf)
+	d.buf = d.buf[n:]
+
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+
+	return string(b), err
+}
+
+// count reads a repeated field's element count. Empty repeated fields are
+// omitted by the encoder, so a count of zero is not canonical.
+func (d *decoder) count() (int, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("%w: empty repeated field", ErrInvalidEncoding)
+	}
+	// every element takes at least one byte
+	if n > uint64(len(d.buf)) {
+		return 0, fmt.Errorf("%w: unexpected end of input", ErrInvalidEncoding)
+	}
+
+	return int(n), nil
+}
+
+// fields calls fn for every field of a message until the terminating zero
+// field number, checking that field numbers are strictly ascending.
+func (d *decoder) fields(fn func(field uint64) error) error {
+	var last uint64
+	for {
+		field, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		if field == 0 {
+			return nil
+		}
+		if field <= last {
+			return fmt.Errorf("%w: field %d out of order", ErrInvalidEncoding, field)
+		}
+		last = field
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *decoder) mutation() (Mutation, error) {
+	var mu Mutation
+	err := d.fields(func(field uint64) error {
+		switch field {
+		case mutationFieldOwner:
+			owner, err := d.string()
+			if err != nil {
+				return err
+			}
+			if owner == "" {
+				return fmt.Errorf("%w: empty owner", ErrInvalidEncoding)
+			}
+			mu.Owner = owner
+		case mutationFieldParents:
+			n, err := d.count()
+			if err != nil {
+				return err
+			}
+			mu.Parents = make([]string, 0, n)
+			for i := 0; i < n; i++ {
+				parent, err := d.string()
+				if err != nil {
+					return err
+				}
+				if i > 0 && parent <= mu.Parents[i-1] {
+					return fmt.Errorf("%w: parents not sorted", ErrInvalidEncoding)
+				}
+				mu.Parents = append(mu.Parents, parent)
+			}
+		case mutationFieldOperations:
+			n, err := d.count()
+			if err != nil {
+				return err
+			}
+			mu.Operations = make([]*Operation, 0, n)
+			for i := 0; i < n; i++ {
+				op, err := d.operation()
+				if err != nil {
+					return err
+				}
+				mu.Operations = append(mu.Operations, op)
+			}
+		case mutationFieldOrigin:
+			origin, err := d.tag()
+			if err != nil {
+				return err
+			}
+			if origin == (Tag{}) {
+				return fmt.Errorf("%w: zero origin", ErrInvalidEncoding)
+			}
+			mu.Origin = origin
+		case mutationFieldCheckpoint:
+			checkpoint, err := d.version()
+			if err != nil {
+				return err
+			}
+			if len(checkpoint) == 0 {
+				return fmt.Errorf("%w: empty checkpoint", ErrInvalidEncoding)
+			}
+			mu.Checkpoint = checkpoint
+		case mutationFieldSignature:
+			signature, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			if len(signature) == 0 {
+				return fmt.Errorf("%w: empty signature", ErrInvalidEncoding)
+			}
+			mu.Signature = signature
+		default:
+			return fmt.Errorf("%w: unknown mutation field %d", ErrInvalidEncoding, field)
+		}
+
+		return nil
+	})
+
+	return mu, err
+}
+
+func (d *decoder) operation() (*Operation, error) {
+	op := &Operation{}
+	err := d.fields(func(field uint64) error {
+		switch field {
+		case operationFieldType:
+			t, err := d.uvarint()
+			if err != nil {
+				return err
+			}
+			// the add type is the zero value and therefore omitted
+			if t != uint64(RemoveOperation) {
+				return fmt.Errorf("%w: operation type %d", ErrInvalidEncoding, t)
+			}
+			op.Type = OperationType(t)
+		case operationFieldKey:
+			key, err := d.string()
+			if err != nil {
+				return err
+			}
+			if key == "" {
+				return fmt.Errorf("%w: empty key", ErrInvalidEncoding)
+			}
+			op.Key = key
+		case operationFieldValue:
+			v, err := d.value()
+			if err != nil {
+				return err
+			}
+			op.Value = v
+		case operationFieldTags:
+			n, err := d.count()
+			if err != nil {
+				return err
+			}
+			op.Tags = make(Tags, n)
+			var last Tag
+			for i := 0; i < n; i++ {
+				tag, err := d.tag()
+				if err != nil {
+					return err
+				}
+				if i > 0 && compareTags(last, tag) >= 0 {
+					return fmt.Errorf("%w: tags not sorted", ErrInvalidEncoding)
+				}
+				op.Tags[tag] = true
+				last = tag
+			}
+		case operationFieldTime:
+			t, err := d.time()
+			if err != nil {
+				return err
+			}
+			op.Time = t
+		case operationFieldContext:
+			v, err := d.version()
+			if err != nil {
+				return err
+			}
+			op.Context = v
+		case operationFieldTimestamp:
+			t, err := d.timestamp()
+			if err != nil {
+				return err
+			}
+			op.Timestamp = t
+		case operationFieldOwner:
+			owner, err := d.nonEmptyString()
+			if err != nil {
+				return err
+			}
+			op.Owner = owner
+		default:
+			return fmt.Errorf("%w: unknown operation field %d", ErrInvalidEncoding, field)
+		}
+
+		return nil
+	})
+
+	return op, err
+}
+
+func (d *decoder) version() (Version, error) {
+	n, err := d.count()
+	if err != nil {
+		return nil, err
+	}
+	v := make(Version, n)
+	var last string
+	for i := 0; i < n; i++ {
+		tag, err := d.tag()
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && tag.ReplicaID <= last {
+			return nil, fmt.Errorf("%w: version not sorted", ErrInvalidEncoding)
+		}
+		if tag.Sequence == 0 {
+			return nil, fmt.Errorf("%w: zero version entry", ErrInvalidEncoding)
+		}
+		v[tag.ReplicaID] = tag.Sequence
+		last = tag.ReplicaID
+	}
+
+	return v, nil
+}
+
+func (d *decoder) time() (time.Time, error) {
+	sec, err := d.varint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	nsec, err := d.uvarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if nsec >= uint64(time.Second) {
+		return time.Time{}, fmt.Errorf("%w: nanoseconds out of range", ErrInvalidEncoding)
+	}
+	t := time.Unix(sec, int64(nsec)).UTC()
+	if t.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: zero time", ErrInvalidEncoding)
+	}
+
+	return t, nil
+}
+
+func (d *decoder) timestamp() (Timestamp, error) {
+	wall, err := d.varint()
+	if err != nil {
+		return Timestamp{}, err
+	}
+	logical, err := d.uvarint()
+	if err != nil {
+		return Timestamp{}, err
+	}
+	if logical > math.MaxUint32 {
+		return Timestamp{}, fmt.Errorf("%w: logical counter out of range", ErrInvalidEncoding)
+	}
+	t := Timestamp{Wall: wall, Logical: uint32(logical)}
+	if t.IsZero() {
+		return Timestamp{}, fmt.Errorf("%w: zero timestamp", ErrInvalidEncoding)
+	}
+
+	return t, nil
+}
+
+func (d *decoder) tag() (Tag, error) {
+	replicaID, err := d.string()
+	if err != nil {
+		return Tag{}, err
+	}
+	sequence, err := d.uvarint()
+	if err != nil {
+		return Tag{}, err
+	}
+
+	return Tag{ReplicaID: replicaID, Sequence: sequence}, nil
+}
+
+func (d *decoder) value() (Value, error) {
+	t, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	switch scalar.Type(t) {
+	case scalar.String:
+		s, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		return scalar.New(s), nil
+	case scalar.Int64:
+		i, err := d.uint64()
+		if err != nil {
+			return nil, err
+		}
+		return scalar.New(int64(i)), nil
+	case scalar.Uint64:
+		u, err := d.uint64()
+		if err != nil {
+			return nil, err
+		}
+		return scalar.New(u), nil
+	case scalar.Float64:
+		f, err := d.uint64()
+		if err != nil {
+			return nil, err
+		}
+		return scalar.New(math.Float64frombits(f)), nil
+	case scalar.ByteSlice:
+		b, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return scalar.New(b), nil
+	case scalar.Bool:
+		b, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b > 1 {
+			return nil, fmt.Errorf("%w: bool value %d", ErrInvalidEncoding, b)
+		}
+		return scalar.New(b == 1), nil
+	case scalar.Timestamp:
+		sec, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		nsec, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if nsec >= uint64(time.Second) {
+			return nil, fmt.Errorf("%w: nanoseconds out of range", ErrInvalidEncoding)
+		}
+		return scalar.New(time.Unix(sec, int64(nsec)).UTC()), nil
+	case scalar.Decimal:
+		dec, err := d.decimal()
+		if err != nil {
+			return nil, err
+		}
+		return scalar.New(dec), nil
+	case scalar.UUID:
+		b, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != len(scalar.UUIDValue{}) {
+			return nil, fmt.Errorf("%w: UUID of %d bytes", ErrInvalidEncoding, len(b))
+		}
+		return scalar.New(scalar.UUIDValue(b)), nil
+	case scalar.Null:
+		return scalar.New(scalar.NullValue{}), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown value type %d", ErrInvalidEncoding, t)
+	}
+}
+
+func (d *decoder) decimal() (scalar.DecimalValue, error) {
+	scale, err := d.byte()
+	if err != nil {
+		return scalar.DecimalValue{}, err
+	}
+	sign, err := d.byte()
+	if err != nil {
+		return scalar.DecimalValue{}, err
+	}
+	magnitude, err := d.bytes()
+	if err != nil {
+		return scalar.DecimalValue{}, err
+	}
+	switch {
+	case sign > 1:
+		return scalar.DecimalValue{}, fmt.Errorf("%w: decimal sign %d", ErrInvalidEncoding, sign)
+	case len(magnitude) > 0 && magnitude[0] == 0:
+		return scalar.DecimalValue{}, fmt.Errorf("%w: leading zero in decimal", ErrInvalidEncoding)
+	case sign == 1 && len(magnitude) == 0:
+		return scalar.DecimalValue{}, fmt.Errorf("%w: negative zero decimal", ErrInvalidEncoding)
+	}
+	coefficient := new(big.Int).SetBytes(magnitude)
+	if sign == 1 {
+		coefficient.Neg(coefficient)
+	}
+
+	return scalar.DecimalValue{Coefficient: coefficient, Scale: scale}, nil
+}
+
+// sortedKeys returns the keys of the map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// compareTags orders tags like Tag.Less.
+func compareTags(a, b Tag) int {
+	switch {
+	case a.Less(b):
+		return -1
+	case b.Less(a):
+		return 1
+	default:
+		return 0
+	}
+}
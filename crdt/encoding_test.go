@@ -0,0 +1,308 @@
+package crdt
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// goldenMutations pins the canonical encoding and hash of mutations. These
+// vectors must never change; a failure means the encoding is no longer
+// compatible with logs written by earlier versions.
+var goldenMutations = []struct {
+	name     string
+	mutation Mutation
+	encoding string
+	hash     string
+}{
+	{
+		name:     "Empty mutation",
+		mutation: Mutation{},
+		encoding: "0100",
+		hash:     "687376c930d7020a32f04c396fc2e5eab49cd09a738fa03d573033416a6a47ce",
+	},
+	{
+		name: "Single add",
+		mutation: Mutation{
+			Operations: []*Operation{{
+				Type:  AddOperation,
+				Key:   "title",
+				Value: scalar.New("hello"),
+				Tags:  Tags{{ReplicaID: "a", Sequence: 1}: true},
+			}},
+		},
+		encoding: "01030102057469746c6503000568656c6c6f04010161010000",
+		hash:     "1d3db56c80b2ea04a6c2774093dd937a248614027742eb0f6bb4c0f17a8d6417",
+	},
+	{
+		name: "Owner, parents, remove and timed add",
+		mutation: Mutation{
+			Owner:   "alice",
+			Parents: []string{"bb", "aa"},
+			Operations: []*Operation{{
+				Type: RemoveOperation,
+				Key:  "title",
+				Tags: Tags{
+					{ReplicaID: "b", Sequence: 2}: true,
+					{ReplicaID: "a", Sequence: 2}: true,
+					{ReplicaID: "a", Sequence: 1}: true,
+				},
+			}, {
+				Type:  AddOperation,
+				Key:   "count",
+				Value: scalar.New(int64(-42)),
+				Tags:  Tags{{ReplicaID: "a", Sequence: 3}: true},
+				Time:  time.Unix(1700000000, 5),
+			}},
+		},
+		encoding: "010105616c69636502020261610262620302010102057469746c650403016101016102016202000205636f756e740301ffffffffffffffd604010161030580c49fd50c050000",
+		hash:     "012d50cb3a9c9564ba49e3c31f206de55e73af766fb6ccdcbf54220f8c3619e5",
+	},
+	{
+		name: "Value types",
+		mutation: Mutation{
+			Operations: []*Operation{
+				{Key: "u", Value: scalar.New(uint64(42)), Tags: Tags{{ReplicaID: "a", Sequence: 1}: true}},
+				{Key: "f", Value: scalar.New(3.14), Tags: Tags{{ReplicaID: "a", Sequence: 1}: true}},
+				{Key: "b", Value: scalar.New([]byte{0, 1, 2}), Tags: Tags{{ReplicaID: "a", Sequence: 1}: true}},
+				{Key: "t", Value: scalar.New(true), Tags: Tags{{ReplicaID: "a", Sequence: 1}: true}},
+			},
+		},
+		encoding: "0103040201750302000000000000002a040101610100020166030340091eb851eb851f04010161010002016203040300010204010161010002017403050104010161010000",
+		hash:     "7075431aa3bd8df5f23d14df078f3f84d1b71d1812c05124198929e709a51bac",
+	},
+	{
+		name: "Origin",
+		mutation: Mutation{
+			Parents: []string{"aa"},
+			Origin:  Tag{ReplicaID: "a", Sequence: 2},
+			Operations: []*Operation{{
+				Key:   "title",
+				Value: scalar.New("hi"),
+				Tags:  Tags{{ReplicaID: "a", Sequence: 2}: true},
+			}},
+		},
+		encoding: "010201026161030102057469746c6503000268690401016102000401610200",
+		hash:     "ab1399eae1554cb80c43d4d3b8f3a958b5771fa8b17e87a8b0de3e2c62dee02f",
+	},
+	{
+		name: "Remove with context",
+		mutation: Mutation{
+			Operations: []*Operation{{
+				Type:    RemoveOperation,
+				Key:     "k",
+				Context: Version{"b": 3, "a": 2},
+			}},
+		},
+		encoding: "010301010102016b06020161020162030000",
+		hash:     "211d12273f1de3e238cce49c879f668419655a76c9e908fec50a764997d58b44",
+	},
+	{
+		name: "Checkpoint",
+		mutation: Mutation{
+			Owner:      "alice",
+			Parents:    []string{"aa"},
+			Origin:     Tag{ReplicaID: "a", Sequence: 3},
+			Checkpoint: Version{"a": 2, "b": 1},
+			Operations: []*Operation{{
+				Key:   "title",
+				Value: scalar.New("hi"),
+				Tags:  Tags{{ReplicaID: "b", Sequence: 1}: true},
+				Owner: "bob",
+			}},
+		},
+		encoding: "010105616c6963650201026161030102057469746c65030002686904010162010803626f620004016103050201610201620100",
+		hash:     "28d4d392fc6fb761cdd9751bb7419573266e8ffb7d79102c1b729e86e8c0cbd2",
+	},
+}
+
+func TestEncodeMutationGolden(t *testing.T) {
+	for _, tc := range goldenMutations {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := EncodeMutation(tc.mutation)
+			require.NoError(t, err)
+			assert.Equal(t, tc.encoding, hex.EncodeToString(b))
+			assert.Equal(t, tc.hash, must(HashMutation(tc.mutation)))
+		})
+	}
+}
+
+func TestDecodeMutationGolden(t *testing.T) {
+	for _, tc := range goldenMutations {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := hex.DecodeString(tc.encoding)
+			require.NoError(t, err)
+
+			mu, err := DecodeMutation(b)
+			require.NoError(t, err)
+			assert.Equal(t, tc.hash, must(HashMutation(mu)))
+
+			again, err := EncodeMutation(mu)
+			require.NoError(t, err)
+			assert.Equal(t, b, again)
+		})
+	}
+}
+
+func TestHashMutationCanonical(t *testing.T) {
+	tags := Tags{}
+	for i := uint64(1); i <= 32; i++ {
+		tags[Tag{ReplicaID: "r", Sequence: i}] = true
+	}
+	mu := Mutation{
+		Parents: []string{"c", "a", "b"},
+		Operations: []*Operation{{
+			Type: RemoveOperation,
+			Key:  "title",
+			Tags: tags,
+		}},
+	}
+	hash := must(HashMutation(mu))
+
+	// map iteration order must not affect the hash
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, hash, must(HashMutation(mu)))
+	}
+
+	reordered := mu
+	reordered.Parents = []string{"b", "c", "a"}
+	assert.Equal(t, hash, must(HashMutation(reordered)))
+
+	// the location of a time must not affect the hash
+	now := time.Now()
+	local := NewMutation(&Operation{Key: "k", Value: scalar.New("v"), Time: now})
+	utc := NewMutation(&Operation{Key: "k", Value: scalar.New("v"), Time: now.UTC()})
+	assert.Equal(t, must(HashMutation(local)), must(HashMutation(utc)))
+
+	// zero context entries are not encoded
+	ctx := NewMutation(&Operation{Type: RemoveOperation, Key: "k", Context: Version{"a": 1}})
+	withZero := NewMutation(&Operation{Type: RemoveOperation, Key: "k", Context: Version{"a": 1, "b": 0}})
+	assert.Equal(t, must(HashMutation(ctx)), must(HashMutation(withZero)))
+
+	// values of different types must not collide
+	i := NewMutation(&Operation{Key: "k", Value: scalar.New(int64(1))})
+	u := NewMutation(&Operation{Key: "k", Value: scalar.New(uint64(1))})
+	assert.NotEqual(t, must(HashMutation(i)), must(HashMutation(u)))
+}
+
+func TestDecodeMutationRoundTrip(t *testing.T) {
+	mu := Mutation{
+		Owner:     "alice",
+		Signature: []byte{1, 2, 3},
+		Parents:   []string{"a", "b"},
+		Operations: []*Operation{{
+			Type:  AddOperation,
+			Key:   "title",
+			Value: scalar.New("hello"),
+			Tags:  Tags{{ReplicaID: "a", Sequence: 7}: true},
+			Time:  time.Date(2024, 5, 1, 12, 0, 0, 42, time.UTC),
+			Timestamp: Timestamp{
+				Wall:    time.Date(2024, 5, 1, 12, 0, 0, 42, time.UTC).UnixNano(),
+				Logical: 3,
+			},
+		}, {
+			Type: RemoveOperation,
+			Key:  "body",
+			Tags: Tags{{ReplicaID: "a", Sequence: 3}: true},
+		}},
+	}
+
+	b, err := EncodeMutation(mu)
+	require.NoError(t, err)
+	got, err := DecodeMutation(b)
+	require.NoError(t, err)
+	assert.Equal(t, mu, got)
+}
+
+func TestDecodeValueRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value Value
+	}{
+		{name: "Timestamp", value: scalar.New(time.Date(2024, 5, 1, 12, 0, 0, 42, time.UTC))},
+		{name: "Timestamp before the epoch", value: scalar.New(time.Date(1900, 1, 1, 0, 0, 0, 1, time.UTC))},
+		{name: "Zero timestamp", value: scalar.New(time.Time{})},
+		{name: "Decimal", value: scalar.New(scalar.NewDecimal(-1250, 2))},
+		{name: "Large decimal", value: scalar.New(must(scalar.ParseDecimal("123456789012345678901234567890.99")))},
+		{name: "Zero decimal", value: scalar.New(scalar.NewDecimal(0, 3))},
+		{name: "UUID", value: scalar.New(must(scalar.ParseUUID("f81d4fae-7dec-11d0-a765-00a0c91e6bf6")))},
+		{name: "Null", value: scalar.New(scalar.NullValue{})},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mu := NewMutation(&Operation{Key: "k", Value: tc.value})
+			b := must(EncodeMutation(mu))
+			got := must(DecodeMutation(b))
+			assert.Equal(t, tc.value.Type(), got.Operations[0].Value.Type())
+			assert.True(t, must(ToAny(tc.value)).Equal(must(ToAny(got.Operations[0].Value))))
+			assert.Equal(t, b, must(EncodeMutation(got)), "re-encoding is stable")
+		})
+	}
+
+	// the location of a timestamp must not affect the hash
+	now := time.Now()
+	local := NewMutation(&Operation{Key: "k", Value: scalar.New(now)})
+	utc := NewMutation(&Operation{Key: "k", Value: scalar.New(now.UTC())})
+	assert.Equal(t, must(HashMutation(local)), must(HashMutation(utc)))
+
+	// the scale of a decimal is part of its value
+	d1 := NewMutation(&Operation{Key: "k", Value: scalar.New(scalar.NewDecimal(15, 1))})
+	d2 := NewMutation(&Operation{Key: "k", Value: scalar.New(scalar.NewDecimal(150, 2))})
+	assert.NotEqual(t, must(HashMutation(d1)), must(HashMutation(d2)))
+}
+
+func TestDecodeMutationInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+	}{
+		{name: "Empty input", encoding: ""},
+		{name: "Unknown version", encoding: "0200"},
+		{name: "Missing terminator", encoding: "01"},
+		{name: "Trailing bytes", encoding: "010000"},
+		{name: "Unknown field", encoding: "010900"},
+		{name: "Fields out of order", encoding: "01020101610105616c69636500"},
+		{name: "Unsorted parents", encoding: "0102020162016100"},
+		{name: "Duplicate parents", encoding: "0102020161016100"},
+		{name: "Empty parents", encoding: "01020000"},
+		{name: "Explicit add type", encoding: "01030101000000"},
+		{name: "Unknown value type", encoding: "010301030a"},
+		{name: "Invalid bool", encoding: "010301030502"},
+		{name: "Non-minimal uvarint", encoding: "01800000"},
+		{name: "Truncated string", encoding: "0101056100"},
+		{name: "Zero origin", encoding: "0104000000"},
+		{name: "Unsorted context", encoding: "01030106020162010161010000"},
+		{name: "Zero context entry", encoding: "01030106010161000000"},
+		{name: "Empty checkpoint", encoding: "01050000"},
+		{name: "Empty signature", encoding: "01060000"},
+		{name: "Zero timestamp", encoding: "0103010700000000"},
+		{name: "Logical counter out of range", encoding: "010301070280808080100000"},
+		{name: "Timestamp nanoseconds out of range", encoding: "010301030600" + "8094ebdc03"},
+		{name: "Invalid decimal sign", encoding: "0103010307000200"},
+		{name: "Leading zero in decimal", encoding: "01030103070000020001"},
+		{name: "Negative zero decimal", encoding: "0103010307000100"},
+		{name: "Short UUID", encoding: "01030103080100"},
+		{name: "Empty operation owner", encoding: "01030108000000"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := hex.DecodeString(tc.encoding)
+			require.NoError(t, err)
+
+			_, err = DecodeMutation(b)
+			assert.ErrorIs(t, err, ErrInvalidEncoding)
+		})
+	}
+}
+
+func TestEncodeMutationInvalid(t *testing.T) {
+	_, err := EncodeMutation(Mutation{Operations: []*Operation{nil}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrCycle is returned for a mutation that would make the log cyclic.
+	ErrCycle = errors.New("mutation creates a cycle")
+	// ErrUnknownParent is reported for a mutation whose parents are not in
+	// the log. The mutation is kept and applied once its parents arrive.
+	ErrUnknownParent = errors.New("unknown parent")
+	// ErrInvalidOperation is returned for a mutation with a malformed
+	// operation.
+	ErrInvalidOperation = errors.New("invalid operation")
+	// ErrUnknownMutation is returned for a hash that is not an applied
+	// mutation of the log.
+	ErrUnknownMutation = errors.New("unknown mutation")
+	// ErrFolded is returned when reading a state of the map whose mutations
+	// were folded into a checkpoint that state does not include, or into the
+	// snapshot the map was restored from.
+	ErrFolded = errors.New("mutation folded into a checkpoint")
+	// ErrUnauthorized is returned for a mutation with an operation that the
+	// authorizer of the map rejects.
+	ErrUnauthorized = errors.New("unauthorized operation")
+	// ErrHashMismatch is returned for an encoded mutation whose contents do
+	// not hash to the hash it came with.
+	ErrHashMismatch = errors.New("hash mismatch")
+	// ErrConflictingDuplicate is returned for an encoded mutation whose hash
+	// is in the log with different contents.
+	ErrConflictingDuplicate = errors.New("duplicate hash with differing contents")
+)
+
+// MutationError reports a mutation passed to ImportLog that was rejected or
+// could not be applied yet.
+type MutationError struct {
+	Index int    // position of the mutation in the imported log
+	Hash  string // hash of the mutation, empty if it could not be computed
+	Err   error
+}
+
+func (e *MutationError) Error() string {
+	if e.Hash == "" {
+		return fmt.Sprintf("mutation %d: %v", e.Index, e.Err)
+	}
+
+	return fmt.Sprintf("mutation %d (%s): %v", e.Index, e.Hash, e.Err)
+}
+
+func (e *MutationError) Unwrap() error {
+	return e.Err
+}
+
+// validateMutation checks that the operations of a mutation are well-formed.
+// Adds are tagged with the origin of the mutation, except in checkpoints,
+// which carry the tags of other mutations. A checkpoint has an origin and
+// only adds tags it covers; only its operations name the owners of the tags.
+func validateMutation(mu Mutation) error {
+	checkpoint := len(mu.Checkpoint) > 0
+	if checkpoint && mu.Origin.ReplicaID == "" {
+		return fmt.Errorf("%w: checkpoint has no origin", ErrInvalidOperation)
+	}
+	for i, op := range mu.Operations {
+		if op == nil {
+			return fmt.Errorf("%w: operation %d is nil", ErrInvalidOperation, i)
+		}
+		if op.Owner != "" && !checkpoint {
+			return fmt.Errorf("%w: operation %d names an owner outside a checkpoint", ErrInvalidOperation, i)
+		}
+
+		switch op.Type {
+		case AddOperation:
+			if op.Value == nil {
+				return fmt.Errorf("%w: operation %d adds no value", ErrInvalidOperation, i)
+			}
+			if len(op.Tags) == 0 {
+				return fmt.Errorf("%w: operation %d adds no tag", ErrInvalidOperation, i)
+			}
+			if !checkpoint {
+				// a tag of another replica would let the owner take over or
+				// remove writes made by others
+				if len(op.Tags) != 1 || !op.Tags[mu.Origin] {
+					return fmt.Errorf("%w: operation %d adds a tag other than the origin of the mutation", ErrInvalidOperation, i)
+				}
+				break
+			}
+			for tag := range op.Tags {
+				if !mu.Checkpoint.Contains(tag) {
+					return fmt.Errorf("%w: operation %d adds a tag the checkpoint does not cover", ErrInvalidOperation, i)
+				}
+			}
+		case RemoveOperation:
+			if checkpoint {
+				return fmt.Errorf("%w: operation %d removes in a checkpoint", ErrInvalidOperation, i)
+			}
+			if op.Value != nil {
+				return fmt.Errorf("%w: operation %d removes a value", ErrInvalidOperation, i)
+			}
+		default:
+			return fmt.Errorf("%w: operation %d has unknown type %d", ErrInvalidOperation, i, op.Type)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package crdt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestValidateMutation(t *testing.T) {
+	origin := Tag{ReplicaID: "a", Sequence: 1}
+	tags := Tags{origin: true}
+	tests := []struct {
+		name string
+		op   *Operation
+		err  error
+	}{
+		{
+			name: "Add",
+			op:   &Operation{Type: AddOperation, Key: "k", Value: scalar.New("v"), Tags: tags},
+		},
+		{
+			name: "Remove",
+			op:   &Operation{Type: RemoveOperation, Key: "k", Tags: tags},
+		},
+		{
+			name: "Remove without tags",
+			op:   &Operation{Type: RemoveOperation, Key: "k"},
+		},
+		{
+			name: "Nil operation",
+			err:  ErrInvalidOperation,
+		},
+		{
+			name: "Add without value",
+			op:   &Operation{Type: AddOperation, Key: "k", Tags: tags},
+			err:  ErrInvalidOperation,
+		},
+		{
+			name: "Add without tags",
+			op:   &Operation{Type: AddOperation, Key: "k", Value: scalar.New("v")},
+			err:  ErrInvalidOperation,
+		},
+		{
+			name: "Remove with value",
+			op:   &Operation{Type: RemoveOperation, Key: "k", Value: scalar.New("v"), Tags: tags},
+			err:  ErrInvalidOperation,
+		},
+		{
+			name: "Unknown type",
+			op:   &Operation{Type: OperationType(7), Key: "k", Tags: tags},
+			err:  ErrInvalidOperation,
+		},
+		{
+			name: "Add with a tag of another replica",
+			op:   &Operation{Type: AddOperation, Key: "k", Value: scalar.New("v"), Tags: Tags{{ReplicaID: "b", Sequence: 1}: true}},
+			err:  ErrInvalidOperation,
+		},
+		{
+			name: "Add with another tag",
+			op:   &Operation{Type: AddOperation, Key: "k", Value: scalar.New("v"), Tags: Tags{origin: true, {ReplicaID: "a", Sequence: 2}: true}},
+			err:  ErrInvalidOperation,
+		},
+		{
+			name: "Add with owner",
+			op:   &Operation{Type: AddOperation, Key: "k", Value: scalar.New("v"), Tags: tags, Owner: "alice"},
+			err:  ErrInvalidOperation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mu := NewMutation(tc.op)
+			mu.Origin = origin
+			err := validateMutation(mu)
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestValidateCheckpoint(t *testing.T) {
+	checkpoint := Version{"a": 1}
+	origin := Tag{ReplicaID: "b", Sequence: 2}
+	tests := []struct {
+		name string
+		mu   Mutation
+		err  error
+	}{
+		{
+			name: "Covered add",
+			mu: Mutation{
+				Operations: []*Operation{{Key: "k", Value: scalar.New("v"), Tags: Tags{{ReplicaID: "a", Sequence: 1}: true}, Owner: "alice"}},
+				Origin:     origin,
+				Checkpoint: checkpoint,
+			},
+		},
+		{
+			name: "Add not covered",
+			mu: Mutation{
+				Operations: []*Operation{{Key: "k", Value: scalar.New("v"), Tags: Tags{{ReplicaID: "a", Sequence: 2}: true}}},
+				Origin:     origin,
+				Checkpoint: checkpoint,
+			},
+			err: ErrInvalidOperation,
+		},
+		{
+			name: "Remove",
+			mu: Mutation{
+				Operations: []*Operation{{Type: RemoveOperation, Key: "k"}},
+				Origin:     origin,
+				Checkpoint: checkpoint,
+			},
+			err: ErrInvalidOperation,
+		},
+		{
+			name: "No origin",
+			mu:   Mutation{Checkpoint: checkpoint},
+			err:  ErrInvalidOperation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMutation(tc.mu)
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestMutationError(t *testing.T) {
+	err := error(&MutationError{Index: 3, Hash: "abc", Err: ErrCycle})
+	assert.Equal(t, "mutation 3 (abc): mutation creates a cycle", err.Error())
+	assert.ErrorIs(t, err, ErrCycle)
+	assert.Equal(t, "mutation 0: invalid operation", (&MutationError{Err: ErrInvalidOperation}).Error())
+	assert.False(t, errors.Is(err, ErrUnknownParent))
+}
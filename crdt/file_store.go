@@ -0,0 +1,362 @@
+package crdt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// recordHeaderSize is the size of the payload length and the checksums that
+// precede every record of a FileStore.
+const recordHeaderSize = 12
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileStore is a Store that appends mutations to a file. Each record holds
+// the length of its payload, a CRC-32C checksum of the length, and a CRC-32C
+// checksum of the length and payload, followed by the payload: the
+// length-prefixed hash and the canonical encoding of the mutation. Put syncs
+// the file before it returns. Delete rewrites the file without the deleted
+// records and replaces the old file with it. A record at the end of the file
+// that was only partly written, because the process stopped during a Put, is
+// cut off when the file is opened again; a record with a damaged length is
+// reported as ErrCorruptStore.
+type FileStore struct {
+	f       *os.File
+	path    string
+	size    int64             // end of the last complete record
+	records map[string]record // hash -> location of the record
+	order   []string          // hashes in file order
+	heads   storeHeads
+	mu      sync.Mutex
+}
+
+type record struct {
+	offset int64
+	size   uint32 // payload size
+}
+
+// OpenFileStore opens the store in the file at path, creating it if it does
+// not exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	_, err := os.Stat(path)
+	created := errors.Is(err, os.ErrNotExist)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	if created {
+		// make the new file itself durable
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	s := &FileStore{
+		f:       f,
+		path:    path,
+		records: make(map[string]record),
+		heads:   newStoreHeads(),
+	}
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+
+	return nil
+}
+
+// load indexes the records of the file and cuts off an incomplete record at
+// its end.
+func (s *FileStore) load() error {
+	info, err := s.f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat store: %w", err)
+	}
+	fileSize := info.Size()
+
+	r := bufio.NewReader(io.NewSectionReader(s.f, 0, fileSize))
+	var header [recordHeaderSize]byte
+	for s.size < fileSize {
+		offset := s.size
+		if fileSize-offset < recordHeaderSize {
+			return s.truncate(offset)
+		}
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return fmt.Errorf("failed to read store: %w", err)
+		}
+		if crc32.Checksum(header[:4], crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+			// a write cut short leaves a prefix of the header, which is
+			// either too short to read or intact
+			return fmt.Errorf("%w: length checksum mismatch at offset %d", ErrCorruptStore, offset)
+		}
+		size := binary.BigEndian.Uint32(header[:4])
+		end := offset + recordHeaderSize + int64(size)
+		if end > fileSize {
+			return s.truncate(offset)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return fmt.Errorf("failed to read store: %w", err)
+		}
+		if recordChecksum(header[:4], payload) != binary.BigEndian.Uint32(header[8:]) {
+			if end == fileSize {
+				return s.truncate(offset)
+			}
+			return fmt.Errorf("%w: checksum mismatch at offset %d", ErrCorruptStore, offset)
+		}
+
+		hash, mu, err := decodeRecord(payload)
+		if err != nil {
+			return fmt.Errorf("%w: record at offset %d: %w", ErrCorruptStore, offset, err)
+		}
+		s.index(hash, mu, record{offset: offset, size: size})
+		s.size = end
+	}
+
+	return nil
+}
+
+// recordChecksum returns the checksum of the encoded length and payload of a
+// record.
+func recordChecksum(length, payload []byte) uint32 {
+	return crc32.Update(crc32.Checksum(length, crcTable), crcTable, payload)
+}
+
+// truncate cuts the file off at size.
+func (s *FileStore) truncate(size int64) error {
+	if err := s.f.Truncate(size); err != nil {
+		return fmt.Errorf("failed to truncate store: %w", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync store: %w", err)
+	}
+	s.size = size
+
+	return nil
+}
+
+func (s *FileStore) index(hash string, mu Mutation, rec record) {
+	if _, exists := s.records[hash]; exists {
+		return
+	}
+	s.records[hash] = rec
+	s.order = append(s.order, hash)
+	s.heads.add(hash, mu.Parents)
+}
+
+func (s *FileStore) Put(hash string, mu Mutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.records[hash]; exists {
+		return nil
+	}
+
+	encoded, err := EncodeMutation(mu)
+	if err != nil {
+		return fmt.Errorf("failed to encode mutation: %w", err)
+	}
+	payload := binary.AppendUvarint(nil, uint64(len(hash)))
+	payload = append(payload, hash...)
+	payload = append(payload, encoded...)
+
+	b := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(b[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], crc32.Checksum(b[:4], crcTable))
+	binary.BigEndian.PutUint32(b[8:], recordChecksum(b[:4], payload))
+	b = append(b, payload...)
+
+	if _, err := s.f.WriteAt(b, s.size); err != nil {
+		// drop whatever part of the record made it to the file
+		_ = s.f.Truncate(s.size)
+		return fmt.Errorf("failed to write mutation: %w", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync store: %w", err)
+	}
+
+	s.index(hash, mu, record{offset: s.size, size: uint32(len(payload))})
+	s.size += int64(len(b))
+
+	return nil
+}
+
+func (s *FileStore) Get(hash string) (Mutation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[hash]
+	if !exists {
+		return Mutation{}, ErrNotFound
+	}
+	_, mu, err := s.read(rec)
+
+	return mu, err
+}
+
+func (s *FileStore) Heads() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.heads.list(), nil
+}
+
+func (s *FileStore) Iterate(fn func(hash string, mu Mutation) error) error {
+	s.mu.Lock()
+	order := slices.Clone(s.order)
+	mutations := make(map[string]Mutation, len(order))
+	for _, hash := range order {
+		_, mu, err := s.read(s.records[hash])
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		mutations[hash] = mu
+	}
+	s.mu.Unlock()
+
+	return iterateCausal(order, mutations, fn)
+}
+
+func (s *FileStore) Delete(hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		if _, exists := s.records[hash]; exists {
+			deleted[hash] = true
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	// copy the remaining records to a new file and move it over the old one,
+	// so the store is never left with only part of them
+	info, err := s.f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat store: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp creates the file with mode 0600
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	records := make(map[string]record, len(s.records)-len(deleted))
+	var size int64
+	for _, hash := range s.order {
+		if deleted[hash] {
+			continue
+		}
+		rec := s.records[hash]
+		b := make([]byte, recordHeaderSize+int64(rec.size))
+		if _, err := s.f.ReadAt(b, rec.offset); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to read mutation: %w", err)
+		}
+		if _, err := w.Write(b); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write mutation: %w", err)
+		}
+		records[hash] = record{offset: size, size: rec.size}
+		size += int64(len(b))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write mutation: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to replace store: %w", err)
+	}
+
+	// the old file is gone, so the store moves on to the new one even if
+	// syncing the directory fails
+	s.f.Close()
+	s.f = tmp
+	s.size = size
+	s.records = make(map[string]record, len(records))
+	order := s.order
+	s.order = nil
+	s.heads = newStoreHeads()
+	for _, hash := range order {
+		rec, exists := records[hash]
+		if !exists {
+			continue
+		}
+		_, mu, err := s.read(rec)
+		if err != nil {
+			return err
+		}
+		s.index(hash, mu, rec)
+	}
+
+	return syncDir(filepath.Dir(s.path))
+}
+
+// Close closes the file of the store.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+func (s *FileStore) read(rec record) (string, Mutation, error) {
+	payload := make([]byte, rec.size)
+	if _, err := s.f.ReadAt(payload, rec.offset+recordHeaderSize); err != nil {
+		return "", Mutation{}, fmt.Errorf("failed to read mutation: %w", err)
+	}
+
+	return decodeRecord(payload)
+}
+
+func decodeRecord(payload []byte) (string, Mutation, error) {
+	n, k := binary.Uvarint(payload)
+	if k <= 0 || n > uint64(len(payload)-k) {
+		return "", Mutation{}, fmt.Errorf("%w: invalid hash length", ErrCorruptStore)
+	}
+	hash := string(payload[k : k+int(n)])
+	mu, err := DecodeMutation(payload[k+int(n):])
+	if err != nil {
+		return "", Mutation{}, fmt.Errorf("failed to decode mutation: %w", err)
+	}
+
+	return hash, mu, nil
+}
@@ -0,0 +1,131 @@
+package crdt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// writeFileStore creates a store at path holding the log of a map with
+// n adds and returns that log.
+func writeFileStore(t *testing.T, path string, n int) []Mutation {
+	t.Helper()
+
+	s, err := OpenFileStore(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	orsetMap := must(NewORSetMap(WithReplicaID("a"), WithStore(s)))
+	for i := 0; i < n; i++ {
+		_, err := orsetMap.Add("key", scalar.New(int64(i)))
+		require.NoError(t, err)
+	}
+
+	return must(orsetMap.ExportLog())
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	log := writeFileStore(t, path, 3)
+
+	s, err := OpenFileStore(path)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, []string{must(HashMutation(log[2]))}, must(s.Heads()))
+
+	reopened := must(NewORSetMap(WithReplicaID("a"), WithStore(s)))
+	assert.Equal(t, scalar.New(int64(2)), reopened.Get("key"))
+	assert.Equal(t, hashLog(log), hashLog(must(reopened.ExportLog())))
+}
+
+func TestFileStoreTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	writeFileStore(t, path, 2)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	log := writeFileStore(t, filepath.Join(t.TempDir(), "log"), 3)
+
+	// every prefix of the third record is cut off again
+	full, err := os.ReadFile(path)
+	require.NoError(t, err)
+	s, err := OpenFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(must(HashMutation(log[2])), log[2]))
+	require.NoError(t, s.Close())
+	withThird, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, full, withThird[:len(full)])
+
+	for size := info.Size() + 1; size < int64(len(withThird)); size += 7 {
+		require.NoError(t, os.WriteFile(path, withThird[:size], 0o644))
+
+		s, err := OpenFileStore(path)
+		require.NoError(t, err)
+		orsetMap := must(NewORSetMap(WithReplicaID("a"), WithStore(s)))
+		assert.Equal(t, scalar.New(int64(1)), orsetMap.Get("key"))
+
+		stat, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, info.Size(), stat.Size())
+
+		// the store accepts writes after the recovery
+		_, err = orsetMap.Add("key", scalar.New("after"))
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	writeFileStore(t, path, 2)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	b[recordHeaderSize+2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, b, 0o644))
+
+	// a damaged record followed by others is not an interrupted write
+	_, err = OpenFileStore(path)
+	assert.ErrorIs(t, err, ErrCorruptStore)
+}
+
+func TestFileStoreCorruptLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	writeFileStore(t, path, 2)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	for _, i := range []int{0, 3} {
+		corrupted := bytes.Clone(b)
+		corrupted[i] ^= 0x40
+		require.NoError(t, os.WriteFile(path, corrupted, 0o644))
+
+		// a length pointing past the end is not taken for a torn tail
+		_, err = OpenFileStore(path)
+		assert.ErrorIs(t, err, ErrCorruptStore, "byte %d", i)
+		after, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, corrupted, after, "byte %d", i)
+	}
+}
+
+func TestFileStoreDeleteKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	log := writeFileStore(t, path, 2)
+	require.NoError(t, os.Chmod(path, 0o640))
+
+	s, err := OpenFileStore(path)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Delete([]string{must(HashMutation(log[0]))}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+}
@@ -0,0 +1,126 @@
+package crdt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxMutationSize bounds the size of a single encoded mutation in a log
+// stream, so a corrupted length prefix cannot trigger a huge allocation.
+const maxMutationSize = 64 << 20
+
+// Encoder writes mutations to a log stream. Each mutation is written as its
+// length followed by its canonical encoding, see EncodeMutation.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes a mutation to the stream.
+func (e *Encoder) Encode(mu Mutation) error {
+	b, err := EncodeMutation(mu)
+	if err != nil {
+		return fmt.Errorf("failed to encode mutation: %w", err)
+	}
+
+	frame := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(b)), uint64(len(b)))
+	frame = append(frame, b...)
+	if _, err := e.w.Write(frame); err != nil {
+		return fmt.Errorf("failed to write mutation: %w", err)
+	}
+
+	return nil
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Decoder reads mutations from a log stream written by an Encoder.
+type Decoder struct {
+	r byteReader
+}
+
+// NewDecoder returns a Decoder reading from r. If r does not implement
+// io.ByteReader it is buffered, and the Decoder may read past the last
+// mutation it returns.
+func NewDecoder(r io.Reader) *Decoder {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &Decoder{r: br}
+}
+
+// Decode reads the next mutation from the stream. It returns io.EOF when the
+// stream ends between mutations and io.ErrUnexpectedEOF when it ends within
+// one.
+func (d *Decoder) Decode() (Mutation, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return Mutation{}, err
+		}
+		return Mutation{}, fmt.Errorf("failed to read mutation length: %w", err)
+	}
+	if n > maxMutationSize {
+		return Mutation{}, fmt.Errorf("%w: mutation of %d bytes exceeds limit", ErrInvalidEncoding, n)
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Mutation{}, fmt.Errorf("failed to read mutation: %w", err)
+	}
+
+	mu, err := DecodeMutation(b)
+	if err != nil {
+		return Mutation{}, fmt.Errorf("failed to decode mutation: %w", err)
+	}
+
+	return mu, nil
+}
+
+// MarshalLog encodes a log, as returned by ORSetMap.ExportLog, into a log
+// stream.
+func MarshalLog(mutations []Mutation) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for i, mu := range mutations {
+		if err := enc.Encode(mu); err != nil {
+			return nil, fmt.Errorf("mutation %d: %w", i, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalLog decodes a log stream into mutations that can be passed to
+// ORSetMap.ImportLog. Values are decoded into scalars of the type they were
+// encoded with.
+func UnmarshalLog(b []byte) ([]Mutation, error) {
+	var mutations []Mutation
+	dec := NewDecoder(bytes.NewReader(b))
+	for {
+		mu, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return mutations, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mutation %d: %w", len(mutations), err)
+		}
+		mutations = append(mutations, mu)
+	}
+}
@@ -0,0 +1,94 @@
+package crdt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestMarshalLogRoundTrip(t *testing.T) {
+	orsetMap := must(NewORSetMap(WithReplicaID("a")))
+	orsetMap.Add("title", scalar.New("Hello"))
+	orsetMap.Add("views", scalar.New(int64(-1)))
+	orsetMap.Add("likes", scalar.New(uint64(2)))
+	orsetMap.Add("score", scalar.New(0.5))
+	orsetMap.Add("avatar", scalar.New([]byte{0xca, 0xfe}))
+	orsetMap.Add("draft", scalar.New(true))
+	orsetMap.Add("title", scalar.New("Hello, World!"))
+	orsetMap.Remove("draft")
+
+	log, err := orsetMap.ExportLog()
+	require.NoError(t, err)
+	b, err := MarshalLog(log)
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalLog(b)
+	require.NoError(t, err)
+	require.Len(t, decoded, len(log))
+	for i := range log {
+		assert.Equal(t, must(HashMutation(log[i])), must(HashMutation(decoded[i])))
+	}
+
+	anotherORSetMap := must(NewORSetMap(WithReplicaID("b")))
+	require.NoError(t, anotherORSetMap.ImportLog(decoded))
+	assert.Equal(t, orsetMap.List(), anotherORSetMap.List())
+	assert.Equal(t, Complete, anotherORSetMap.State())
+	assert.Equal(t, map[string]Value{
+		"title":  scalar.New("Hello, World!"),
+		"views":  scalar.New(int64(-1)),
+		"likes":  scalar.New(uint64(2)),
+		"score":  scalar.New(0.5),
+		"avatar": scalar.New([]byte{0xca, 0xfe}),
+	}, anotherORSetMap.List())
+}
+
+func TestDecoderStream(t *testing.T) {
+	first := NewMutation(&Operation{Key: "a", Value: scalar.New("1"), Tags: Tags{{ReplicaID: "r", Sequence: 1}: true}})
+	second := NewMutation(&Operation{Key: "b", Value: scalar.New("2"), Tags: Tags{{ReplicaID: "r", Sequence: 2}: true}})
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	require.NoError(t, enc.Encode(first))
+	require.NoError(t, enc.Encode(second))
+
+	// hide the io.ByteReader implementation of bytes.Buffer
+	dec := NewDecoder(io.MultiReader(&buf))
+	mu, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, first, mu)
+	mu, err = dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, second, mu)
+	_, err = dec.Decode()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestUnmarshalLogInvalid(t *testing.T) {
+	b, err := MarshalLog([]Mutation{
+		NewMutation(&Operation{Key: "a", Value: scalar.New("1"), Tags: Tags{{ReplicaID: "r", Sequence: 1}: true}}),
+	})
+	require.NoError(t, err)
+
+	_, err = UnmarshalLog(b[:len(b)-1])
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = UnmarshalLog(b[:1])
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	corrupted := bytes.Clone(b)
+	corrupted[1] = 0x02
+	_, err = UnmarshalLog(corrupted)
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+	_, err = UnmarshalLog([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+
+	mutations, err := UnmarshalLog(nil)
+	require.NoError(t, err)
+	assert.Empty(t, mutations)
+}
@@ -1,8 +1,13 @@
 package crdt
 
 import (
-	"encoding/json"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
@@ -20,6 +25,10 @@ type Value interface {
 	Float64() (float64, bool)
 	ByteSlice() ([]byte, bool)
 	Bool() (bool, bool)
+	Timestamp() (time.Time, bool)
+	Decimal() (scalar.DecimalValue, bool)
+	UUID() (scalar.UUIDValue, bool)
+	Null() (scalar.NullValue, bool)
 }
 
 type OperationType int
@@ -27,6 +36,9 @@ type OperationType int
 const (
 	AddOperation OperationType = iota
 	RemoveOperation
+	// CheckpointOperation is the type authorizers are asked about for
+	// checkpoints. No operation of a mutation has it.
+	CheckpointOperation
 )
 
 type State int
@@ -39,85 +51,265 @@ const (
 
 type (
 	Tag struct {
-		Sequence uint64
+		ReplicaID string
+		Sequence  uint64
 	}
 	Tags     map[Tag]bool
 	Mutation struct {
 		Operations []*Operation
 		Parents    []string
 		Owner      string
+		Origin     Tag     // replica and sequence the mutation was created with
+		Checkpoint Version // checkpoints: the version of the mutations folded into it
+		Signature  []byte  // signature of the owner over the rest of the mutation
 	}
 	Operation struct {
-		Type  OperationType
-		Key   string
-		Value Value
-		Tags  Tags
-		Time  time.Time
+		Type      OperationType
+		Key       string
+		Value     Value
+		Tags      Tags
+		Time      time.Time // wall time of Timestamp, which may run ahead of the writer's clock
+		Timestamp Timestamp // hybrid logical clock time of the write, shared by the operations of a mutation
+		Context   Version   // every tag of the key covered by it is overwritten or removed
+		Owner     string    // checkpoints: owner of the mutation that added the tags
 	}
 	ValueDetail struct {
 		Value     Value
 		Tombstone bool
+		Owner     string    // owner of the mutation that added the value
+		Hash      string    // mutation that added the value
+		Time      time.Time // time of the write that added the value
+		Timestamp Timestamp // hybrid logical clock time of the write that added the value
 	}
 	KeyValue struct {
 		Tags map[Tag]*ValueDetail
+		live map[Tag]struct{} // tags that are not tombstoned; rebuilt when nil
 	}
 	ORSetMap struct {
-		state     State
-		sequence  uint64
-		mutations map[string]bool // mutations applied
-		elements  map[string]*KeyValue
-		hasher    func(Mutation) string
-		log       graph.Graph[string, Mutation]
-		mu        sync.Mutex
+		replicaID     string
+		state         State
+		sequence      uint64
+		mutations     map[string]bool             // mutations in the log, true once applied
+		aliases       map[string]struct{}         // folded mutations still named as parents
+		discarded     []string                    // folded mutations still in the store
+		pending       map[string]*pendingMutation // mutations waiting for parents
+		waiting       map[string][]string         // unapplied parent -> mutations waiting for it
+		heads         map[string]struct{}         // applied mutations without applied children
+		order         []string                    // applied mutations in causal order
+		version       Version                     // greatest applied origin sequence per replica
+		checkpoint    Version                     // version of the applied checkpoints
+		acks          map[string]Version          // versions other replicas acknowledged
+		subscriptions map[*subscription]struct{}  // subscribers to changes of keys
+		events        []func()                    // callbacks to run once mu is released
+		onPending     func(hash string)           // called when a parked mutation is applied
+		elements      map[string]*KeyValue
+		clock         hybridClock         // stamps local mutations
+		owner         string              // owner of local mutations
+		signingKey    ed25519.PrivateKey  // signs local mutations; nil to leave them unsigned
+		keyring       Keyring             // verifies imported mutations; nil to accept any
+		authorizer    Authorizer          // checks the operations of mutations; nil to allow any
+		resolver      Resolver            // resolves keys without a key resolver; nil for GreatestTag
+		keyResolvers  map[string]Resolver // key prefix -> resolver
+		hasher        func(Mutation) (string, error)
+		log           graph.Graph[string, string] // hashes linked to their parents
+		store         Store
+		mu            sync.Mutex
+	}
+	pendingMutation struct {
+		mutation Mutation
+		missing  int // parents not applied yet
 	}
 )
 
-// MarshalJSON implements the json.Marshaler interface for the Tags type.
-// This is needed for the current dummpy implementation of HashMutation.
-// TODO: Remove once a proper hashing function is implemented.
-func (t Tags) MarshalJSON() ([]byte, error) {
-	m := make(map[string]bool)
-	for tag := range t {
-		m[fmt.Sprintf("%d", tag.Sequence)] = true
+// HashMutation returns the hex encoded BLAKE3 hash of the canonical binary
+// encoding of the mutation.
+func HashMutation(mu Mutation) (string, error) {
+	b, err := EncodeMutation(mu)
+	if err != nil {
+		return "", err
 	}
-	return json.Marshal(m)
+	return fmt.Sprintf("%x", blake3.Sum256(b)), nil
 }
 
-// TODO: Implement a proper hashing function
-func HashMutation(mu Mutation) string {
-	json, err := json.Marshal(mu)
-	if err != nil {
-		panic(err)
+// Less reports whether t orders before other. Tags are ordered by sequence
+// first and replica ID second, so concurrent tags with the same sequence are
+// ordered identically on every replica.
+func (t Tag) Less(other Tag) bool {
+	if t.Sequence != other.Sequence {
+		return t.Sequence < other.Sequence
 	}
-	return fmt.Sprintf("%x", blake3.Sum256(json))
+
+	return t.ReplicaID < other.ReplicaID
 }
 
+// Resolve returns the value of the greatest live tag, or nil if all tags are
+// tombstoned.
 func (kv *KeyValue) Resolve() Value {
-	var maxPriority int
+	_, value := kv.winner()
+
+	return value
+}
+
+// winner returns the greatest live tag and its value. The value is nil if
+// all tags are tombstoned.
+func (kv *KeyValue) winner() (Tag, Value) {
+	var maxTag Tag
 	var maxValue Value
-	for tag, value := range kv.Tags {
-		if int(tag.Sequence) > maxPriority && !value.Tombstone {
-			maxPriority = int(tag.Sequence)
-			maxValue = value.Value
+	for tag := range kv.liveTags() {
+		if maxValue == nil || maxTag.Less(tag) {
+			maxTag = tag
+			maxValue = kv.Tags[tag].Value
 		}
 	}
 
-	return maxValue
+	return maxTag, maxValue
+}
+
+// liveTags returns the tags of the key that are not tombstoned. Keys are
+// edited through set, tombstone and drop to keep them up to date, so that
+// overwriting a key does not visit all of its tombstones.
+func (kv *KeyValue) liveTags() map[Tag]struct{} {
+	if kv.live == nil {
+		kv.live = make(map[Tag]struct{})
+		for tag, value := range kv.Tags {
+			if !value.Tombstone {
+				kv.live[tag] = struct{}{}
+			}
+		}
+	}
+
+	return kv.live
+}
+
+func (kv *KeyValue) set(tag Tag, detail *ValueDetail) {
+	kv.Tags[tag] = detail
+	if kv.live == nil {
+		return
+	}
+	if detail.Tombstone {
+		delete(kv.live, tag)
+	} else {
+		kv.live[tag] = struct{}{}
+	}
+}
+
+func (kv *KeyValue) tombstone(tag Tag) {
+	if value, exists := kv.Tags[tag]; exists {
+		value.Tombstone = true
+		delete(kv.live, tag)
+	}
+}
+
+func (kv *KeyValue) drop(tag Tag) {
+	delete(kv.Tags, tag)
+	delete(kv.live, tag)
+}
+
+// Option configures an ORSetMap.
+type Option func(*ORSetMap)
+
+// WithReplicaID sets the ID of the replica the map belongs to. The ID is
+// carried by every tag the map mints and must be stable and unique across
+// replicas of the same map.
+func WithReplicaID(id string) Option {
+	return func(o *ORSetMap) {
+		o.replicaID = id
+	}
+}
+
+// WithPendingAppliedHandler sets a function that is called with the hash of
+// every mutation that was waiting for missing parents once it is applied. The
+// function is called without the map being locked, in the order the mutations
+// were applied.
+func WithPendingAppliedHandler(fn func(hash string)) Option {
+	return func(o *ORSetMap) {
+		o.onPending = fn
+	}
 }
 
-func NewORSetMap() *ORSetMap {
-	return &ORSetMap{
-		state:     Empty,
-		sequence:  0,
-		mutations: make(map[string]bool),
-		elements:  make(map[string]*KeyValue),
-		hasher:    HashMutation,
+// WithStore sets the store that holds the mutations of the map. The map is
+// rebuilt from the mutations already in the store. Without WithStore the
+// mutations are kept in memory.
+func WithStore(store Store) Option {
+	return func(o *ORSetMap) {
+		o.store = store
+	}
+}
+
+// NewORSetMap creates an ORSetMap and applies the mutations of its store.
+// Without WithReplicaID a random replica ID is generated, so a map that is
+// reopened from a persistent store should be given its previous ID.
+func NewORSetMap(opts ...Option) (*ORSetMap, error) {
+	o := newORSetMap(opts...)
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// newORSetMap creates an empty ORSetMap without reading its store.
+func newORSetMap(opts ...Option) *ORSetMap {
+	o := &ORSetMap{
+		state:         Empty,
+		sequence:      0,
+		mutations:     make(map[string]bool),
+		aliases:       make(map[string]struct{}),
+		pending:       make(map[string]*pendingMutation),
+		waiting:       make(map[string][]string),
+		heads:         make(map[string]struct{}),
+		version:       make(Version),
+		checkpoint:    make(Version),
+		acks:          make(map[string]Version),
+		subscriptions: make(map[*subscription]struct{}),
+		elements:      make(map[string]*KeyValue),
+		clock:         hybridClock{now: time.Now, maxSkew: DefaultMaxClockSkew},
+		hasher:        HashMutation,
 		log: graph.New(
-			HashMutation,
+			graph.StringHash,
 			graph.Directed(),
-			graph.Acyclic(),
+			graph.PreventCycles(),
 		),
 	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.replicaID == "" {
+		o.replicaID = newReplicaID()
+	}
+	if o.store == nil {
+		o.store = NewMemoryStore()
+	}
+
+	return o
+}
+
+// load applies the mutations of the store that are not in the log yet.
+func (o *ORSetMap) load() error {
+	err := o.store.Iterate(func(hash string, mu Mutation) error {
+		if _, err := o.appendMutation(mu); err != nil {
+			return fmt.Errorf("failed to load mutation with hash %s: %w", hash, err)
+		}
+		return nil
+	})
+	o.events = nil
+
+	return err
+}
+
+// newReplicaID returns a random replica ID.
+func newReplicaID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// ReplicaID returns the ID of the replica the map belongs to.
+func (o *ORSetMap) ReplicaID() string {
+	return o.replicaID
 }
 
 func NewMutation(operations ...*Operation) Mutation {
@@ -126,170 +318,329 @@ func NewMutation(operations ...*Operation) Mutation {
 	}
 }
 
-func (o *ORSetMap) getLeaves() ([]string, error) {
-	am, err := o.log.AdjacencyMap()
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve adjacency map: %w", err)
+// getLeaves returns the heads of the log, the applied mutations that no other
+// applied mutation has as a parent, in sorted order.
+func (o *ORSetMap) getLeaves() []string {
+	leaves := make([]string, 0, len(o.heads))
+	for hash := range o.heads {
+		leaves = append(leaves, hash)
 	}
+	slices.Sort(leaves)
+
+	return leaves
+}
 
-	found := map[string]struct{}{}
-	for k := range am {
-		found[k] = struct{}{}
+// appendMutation validates and authorizes the mutation, adds it to the log and
+// applies it. A mutation whose parents are not applied yet is parked until the
+// parents arrive. Mutations already in the log, or folded into a checkpoint,
+// are ignored.
+func (o *ORSetMap) appendMutation(mu Mutation) (string, error) {
+	if err := validateMutation(mu); err != nil {
+		return "", err
+	}
+	hash, err := o.hasher(mu)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+	}
+	if err := o.authorize(mu); err != nil {
+		return hash, err
+	}
+	if _, exists := o.mutations[hash]; exists {
+		return hash, nil
 	}
-	for _, v := range am {
-		for kk := range v {
-			delete(found, kk)
+	if o.folded(mu) {
+		// only kept as an alias for the mutations that name it
+		if len(o.waiting[hash]) > 0 {
+			o.drain(o.adopt([]string{hash}))
 		}
+		return hash, nil
+	}
+	if slices.Contains(mu.Parents, hash) {
+		return hash, ErrCycle
 	}
 
-	leaves := []string{}
-	for k := range found {
-		leaves = append(leaves, k)
+	if err := o.log.AddVertex(hash); err != nil {
+		return hash, fmt.Errorf("failed to add mutation with hash %s: %w", hash, err)
+	}
+	// link the mutation to its known parents, and the mutations that arrived
+	// before it to the mutation
+	var edges [][2]string
+	for _, parent := range mu.Parents {
+		if _, exists := o.mutations[parent]; exists {
+			edges = append(edges, [2]string{hash, parent})
+		}
 	}
+	for _, child := range o.waiting[hash] {
+		edges = append(edges, [2]string{child, hash})
+	}
+	for i, edge := range edges {
+		err := o.log.AddEdge(edge[0], edge[1])
+		if err == nil || errors.Is(err, graph.ErrEdgeAlreadyExists) {
+			continue
+		}
 
-	return leaves, nil
-}
+		o.unlink(hash, edges[:i])
+		if errors.Is(err, graph.ErrEdgeCreatesCycle) {
+			return hash, ErrCycle
+		}
+		return hash, fmt.Errorf("failed to link mutation with hash %s to parent %s: %w", edge[0], edge[1], err)
+	}
+	if err := o.store.Put(hash, mu); err != nil {
+		o.unlink(hash, edges)
+		return hash, fmt.Errorf("failed to store mutation with hash %s: %w", hash, err)
+	}
+	// only accepted mutations advance the clock
+	for _, op := range mu.Operations {
+		o.clock.observe(op.Timestamp)
+	}
 
-func (o *ORSetMap) appendMutation(mus ...Mutation) error {
-	for _, mu := range mus {
-		o.log.AddVertex(mu)
-		for _, parent := range mu.Parents {
-			o.log.AddEdge(o.hasher(mu), parent)
+	o.applyHashedMutation(hash, mu)
+	if len(mu.Checkpoint) > 0 {
+		if err := o.fold(hash, mu.Checkpoint); err != nil {
+			return hash, err
 		}
 	}
 
-	// TODO: Rewrite applies. This is very simplistic and inefficient.
-	musOrd, err := o.gerOrderedMutations()
-	if err != nil {
-		return fmt.Errorf("failed to retrieve ordered mutations: %w", err)
+	return hash, nil
+}
+
+// unlink takes a mutation that was not applied out of the log again, along
+// with the given edges.
+func (o *ORSetMap) unlink(hash string, edges [][2]string) {
+	for _, edge := range edges {
+		_ = o.log.RemoveEdge(edge[0], edge[1])
 	}
+	_ = o.log.RemoveVertex(hash)
+}
 
-	for _, mu := range musOrd {
-		muHash := o.hasher(mu)
-		mu, err := o.log.Vertex(muHash)
-		if err != nil {
-			return fmt.Errorf("failed to retrieve mutation with hash %s: %w", muHash, err)
-		}
-		o.applyMutation(mu)
+// applyMutation applies the mutation if all of its parents are applied,
+// followed by every parked mutation that becomes applicable as a result.
+// Otherwise the mutation is parked until its missing parents are applied.
+func (o *ORSetMap) applyMutation(mu Mutation) error {
+	hash, err := o.hasher(mu)
+	if err != nil {
+		return err
 	}
+	o.applyHashedMutation(hash, mu)
 
 	return nil
 }
 
-func (o *ORSetMap) applyMutation(mu Mutation) {
-	if _, applied := o.mutations[o.hasher(mu)]; applied {
+// applyHashedMutation is applyMutation for a mutation whose hash is known.
+func (o *ORSetMap) applyHashedMutation(hash string, mu Mutation) {
+	if _, exists := o.mutations[hash]; exists {
 		return
 	}
 
+	// a checkpoint stands in for the mutations it folds, so the parents it
+	// names count as applied
+	var released []string
+	if len(mu.Checkpoint) > 0 {
+		released = o.adopt(mu.Parents)
+	}
+
 	// check if all parents are applied
+	missing := 0
 	for _, parent := range mu.Parents {
 		if !o.mutations[parent] {
-			o.state = Partial
-			o.mutations[o.hasher(mu)] = false
-			return
+			o.waiting[parent] = append(o.waiting[parent], hash)
+			missing++
+		}
+	}
+	if missing > 0 {
+		o.mutations[hash] = false
+		o.pending[hash] = &pendingMutation{
+			mutation: mu,
+			missing:  missing,
 		}
+		o.state = Partial
+		return
+	}
+
+	o.apply(hash, mu)
+	o.drain(append(released, o.release(hash)...))
+}
+
+// drain applies the given parked mutations, whose parents are all applied,
+// followed by every parked mutation that becomes applicable as a result.
+func (o *ORSetMap) drain(queue []string) {
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+
+		mu := o.pending[hash].mutation
+		if o.folded(mu) {
+			// a checkpoint applied since covers it; it is only kept as an
+			// alias for the mutations that name it
+			if len(o.waiting[hash]) > 0 {
+				queue = append(queue, o.adopt([]string{hash})...)
+			} else {
+				o.forget(hash, mu)
+			}
+			continue
+		}
+
+		delete(o.pending, hash)
+		o.apply(hash, mu)
+		if o.onPending != nil {
+			o.notify(func() { o.onPending(hash) })
+		}
+		queue = append(queue, o.release(hash)...)
+	}
+
+	if len(o.pending) > 0 {
+		o.state = Partial
+		return
+	}
+
+	// all mutations applied
+	o.state = Complete
+}
+
+// release counts the given mutation as applied for the mutations waiting for
+// it, and returns the ones that have no missing parents left.
+func (o *ORSetMap) release(hash string) []string {
+	var released []string
+	for _, child := range o.waiting[hash] {
+		p := o.pending[child]
+		p.missing--
+		if p.missing == 0 {
+			released = append(released, child)
+		}
+	}
+	delete(o.waiting, hash)
+
+	return released
+}
+
+// apply applies the operations of a mutation whose parents are all applied.
+func (o *ORSetMap) apply(hash string, mu Mutation) {
+	if len(o.subscriptions) > 0 {
+		defer o.publish(hash, mu, o.winners(mu))
 	}
 
 	// mark mutation as applied
-	o.mutations[o.hasher(mu)] = true
+	o.mutations[hash] = true
+	o.order = append(o.order, hash)
 
-	// apply operations
+	// the mutation replaces its parents as a head
+	for _, parent := range mu.Parents {
+		delete(o.heads, parent)
+	}
+	o.heads[hash] = struct{}{}
+
+	if len(mu.Checkpoint) > 0 {
+		// the version does not cover the checkpoint's own origin yet, so the
+		// tags it carries up to that origin are added
+		applyCheckpoint(o.elements, hash, mu, o.version)
+		for replica, seq := range mu.Checkpoint {
+			if seq > o.version[replica] {
+				o.version[replica] = seq
+			}
+			if seq > o.checkpoint[replica] {
+				o.checkpoint[replica] = seq
+			}
+			if seq > o.sequence {
+				o.sequence = seq
+			}
+		}
+	} else if seq := applyOperations(o.elements, hash, mu); seq > o.sequence {
+		// advance the local sequence past every observed tag so that local
+		// adds always win over the writes they observed
+		o.sequence = seq
+	}
+
+	if origin := mu.Origin; origin.ReplicaID != "" {
+		if origin.Sequence > o.version[origin.ReplicaID] {
+			o.version[origin.ReplicaID] = origin.Sequence
+		}
+		if origin.Sequence > o.sequence {
+			o.sequence = origin.Sequence
+		}
+	}
+}
+
+// applyOperations applies the operations of the mutation with the given
+// hash to the elements and returns the greatest sequence of the tags they add.
+func applyOperations(elements map[string]*KeyValue, hash string, mu Mutation) uint64 {
+	var seq uint64
 	for _, op := range mu.Operations {
 		switch op.Type {
 		case AddOperation:
 			// TODO: Can there be more than one tags on an add operation?
-			elem, exists := o.elements[op.Key]
+			elem, exists := elements[op.Key]
 			if !exists {
-				o.elements[op.Key] = &KeyValue{
+				elements[op.Key] = &KeyValue{
 					Tags: map[Tag]*ValueDetail{},
 				}
-				elem = o.elements[op.Key]
+				elem = elements[op.Key]
+			}
+			// the add overwrites the values it observed
+			if len(op.Context) > 0 {
+				for tag := range elem.liveTags() {
+					if op.Context.Contains(tag) {
+						elem.tombstone(tag)
+					}
+				}
 			}
 			for tag := range op.Tags {
-				elem.Tags[tag] = &ValueDetail{
-					Value: op.Value,
+				elem.set(tag, &ValueDetail{
+					Value:     op.Value,
+					Owner:     mu.Owner,
+					Hash:      hash,
+					Time:      op.Time.Round(0).UTC(),
+					Timestamp: op.Timestamp,
+				})
+				if tag.Sequence > seq {
+					seq = tag.Sequence
 				}
 			}
 		case RemoveOperation:
-			if elem, ok := o.elements[op.Key]; ok {
+			if elem, ok := elements[op.Key]; ok {
 				for tag := range op.Tags {
-					if value, exists := elem.Tags[tag]; exists {
-						value.Tombstone = true
+					elem.tombstone(tag)
+				}
+				if len(op.Context) > 0 {
+					for tag := range elem.liveTags() {
+						if op.Context.Contains(tag) {
+							elem.tombstone(tag)
+						}
 					}
 				}
 			}
 		}
 	}
 
-	// check if all mutations are applied
-	for _, applied := range o.mutations {
-		if !applied {
-			o.state = Partial
-			return
-		}
-	}
-
-	// all mutations applied
-	o.state = Complete
+	return seq
 }
 
-// gerOrderedMutations returns the mutations in the log in a stable topological
-// order.
-func (o *ORSetMap) gerOrderedMutations() ([]Mutation, error) {
-	order, err := graph.StableTopologicalSort(
-		o.log,
-		// TODO: Reconsider the comparison function.
-		func(a, b string) bool {
-			return a < b
-		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to topologically sort the log: %w", err)
-	}
-
-	var mutations []Mutation
-	for _, hash := range order {
-		mu, err := o.log.Vertex(hash)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve mutation with hash %s: %w", hash, err)
-		}
-		mutations = append(mutations, mu)
-	}
+// Add sets the value of key and returns the hash of the resulting mutation.
+func (o *ORSetMap) Add(key string, value Value) (string, error) {
+	o.mu.Lock()
+	defer o.unlock()
 
-	// reverse mutations
-	for i, j := 0, len(mutations)-1; i < j; i, j = i+1, j-1 {
-		mutations[i], mutations[j] = mutations[j], mutations[i]
+	origin := o.nextOrigin()
+	mu := Mutation{
+		Operations: []*Operation{o.addOperation(key, value, origin)},
+		Parents:    o.getLeaves(),
+		Origin:     origin,
 	}
 
-	return mutations, nil
+	return o.commit(mu)
 }
 
-func (o *ORSetMap) Add(key string, value Value) {
-	o.mu.Lock()
-	defer o.mu.Unlock()
-
-	o.sequence++
-
-	leaves, err := o.getLeaves()
-	if err != nil {
-		// TODO: Remove panic
-		panic(err)
-	}
-
-	op := Operation{
+// addOperation returns an operation that sets the value of key with the
+// origin of its mutation as the tag. It overwrites every observed value.
+func (o *ORSetMap) addOperation(key string, value Value, origin Tag) *Operation {
+	return &Operation{
 		Type:  AddOperation,
 		Key:   key,
 		Value: value,
 		Tags: map[Tag]bool{
-			{Sequence: o.sequence}: true,
+			origin: true,
 		},
-		Time: time.Now(),
-	}
-	mu := Mutation{
-		Operations: []*Operation{&op},
-		Parents:    leaves,
+		Context: maps.Clone(o.version),
 	}
-	o.appendMutation(mu)
 }
 
 func (o *ORSetMap) Get(key string) Value {
@@ -301,37 +652,175 @@ func (o *ORSetMap) Get(key string) Value {
 		return nil
 	}
 
-	return elem.Resolve()
+	return o.resolve(key, elem)
 }
 
-func (o *ORSetMap) Remove(key string) {
+// Remove removes key and returns the hash of the resulting mutation.
+func (o *ORSetMap) Remove(key string) (string, error) {
 	o.mu.Lock()
-	defer o.mu.Unlock()
+	defer o.unlock()
 
-	leaves, err := o.getLeaves()
-	if err != nil {
-		// TODO: Remove panic
-		panic(err)
+	mu := Mutation{
+		Operations: []*Operation{o.removeOperation(key)},
+		Parents:    o.getLeaves(),
+		Origin:     o.nextOrigin(),
 	}
 
+	return o.commit(mu)
+}
+
+// removeOperation returns an operation that removes every observed tag of
+// key.
+func (o *ORSetMap) removeOperation(key string) *Operation {
+	// the version removes every observed tag, so tags dropped by Compact
+	// are removed on replicas that still hold them as well; only tags it
+	// does not cover are listed
+	context := maps.Clone(o.version)
 	tagsToBeRemoved := make(map[Tag]bool)
 	if elem, exists := o.elements[key]; exists {
 		for tag := range elem.Tags {
-			tagsToBeRemoved[tag] = true
+			if !context.Contains(tag) {
+				tagsToBeRemoved[tag] = true
+			}
 		}
 	}
-	op := Operation{
-		Type: RemoveOperation,
-		Key:  key,
-		Tags: tagsToBeRemoved,
-		Time: time.Now(),
+
+	return &Operation{
+		Type:    RemoveOperation,
+		Key:     key,
+		Tags:    tagsToBeRemoved,
+		Context: context,
 	}
-	mu := Mutation{
-		Operations: []*Operation{&op},
-		Parents:    leaves,
+}
+
+// commit stamps a local mutation with the clock, signs it and appends it to
+// the log. If the mutation is rejected the sequence it was minted with is
+// given back.
+func (o *ORSetMap) commit(mu Mutation) (string, error) {
+	now, err := o.clock.tick()
+	if err != nil {
+		o.sequence--
+		return "", fmt.Errorf("failed to stamp mutation: %w", err)
+	}
+	for _, op := range mu.Operations {
+		op.Time = now.Time()
+		op.Timestamp = now
+	}
+	mu, err = o.sign(mu)
+	if err != nil {
+		o.sequence--
+		return "", err
+	}
+	hash, err := o.appendMutation(mu)
+	if err != nil {
+		o.sequence--
+		return "", fmt.Errorf("failed to append mutation: %w", err)
 	}
 
-	o.appendMutation(mu)
+	return hash, nil
+}
+
+// notify queues fn to run once the map is unlocked. It must be called with
+// o.mu held.
+func (o *ORSetMap) notify(fn func()) {
+	o.events = append(o.events, fn)
+}
+
+// unlock releases o.mu and runs the callbacks queued while it was held.
+func (o *ORSetMap) unlock() {
+	events := o.events
+	o.events = nil
+	o.mu.Unlock()
+
+	for _, fn := range events {
+		fn()
+	}
+}
+
+// nextOrigin returns the origin of the next local mutation.
+func (o *ORSetMap) nextOrigin() Tag {
+	o.sequence++
+
+	return Tag{ReplicaID: o.replicaID, Sequence: o.sequence}
+}
+
+// Heads returns the hashes of the applied mutations that are not the parent
+// of another applied mutation, in sorted order. Local mutations use them as
+// parents.
+func (o *ORSetMap) Heads() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.getLeaves()
+}
+
+// Has reports whether the log contains the mutation with the given hash,
+// whether it is applied or waiting for its parents.
+func (o *ORSetMap) Has(hash string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_, exists := o.mutations[hash]
+
+	return exists
+}
+
+// Mutation returns the mutation with the given hash from the log.
+func (o *ORSetMap) Mutation(hash string) (Mutation, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, exists := o.mutations[hash]; !exists {
+		return Mutation{}, false
+	}
+	mu, err := o.store.Get(hash)
+	if err != nil {
+		return Mutation{}, false
+	}
+
+	return mu, true
+}
+
+// MissingParents returns the hashes of the parents that mutations in the log
+// are waiting for but that are not in the log themselves, in sorted order.
+// Fetching them, and in turn their missing parents, completes the log.
+func (o *ORSetMap) MissingParents() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	missing := make([]string, 0)
+	for parent := range o.waiting {
+		if _, exists := o.mutations[parent]; !exists {
+			missing = append(missing, parent)
+		}
+	}
+	slices.Sort(missing)
+
+	return missing
+}
+
+// PendingMutations returns the hashes of the mutations in the log that are
+// waiting for parents to be applied, in sorted order.
+func (o *ORSetMap) PendingMutations() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	pending := make([]string, 0, len(o.pending))
+	for hash := range o.pending {
+		pending = append(pending, hash)
+	}
+	slices.Sort(pending)
+
+	return pending
+}
+
+// Version returns the version vector of the applied mutations. Mutations
+// without an origin are not reflected in it.
+func (o *ORSetMap) Version() Version {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return maps.Clone(o.version)
 }
 
 func (o *ORSetMap) State() State {
@@ -365,7 +854,7 @@ func (o *ORSetMap) List() map[string]Value {
 
 	result := make(map[string]Value)
 	for key, elem := range o.elements {
-		value := elem.Resolve()
+		value := o.resolve(key, elem)
 		if value != nil {
 			result[key] = value
 		}
@@ -374,20 +863,82 @@ func (o *ORSetMap) List() map[string]Value {
 	return result
 }
 
+// ExportLog returns the mutations of the log. Applied mutations are returned
+// in the causal order they were applied in, followed by the parked ones.
+// Mutations covered by the snapshot a map was restored from are only
+// returned if its store holds them.
 func (o *ORSetMap) ExportLog() ([]Mutation, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	return o.gerOrderedMutations()
+	mutations := make([]Mutation, 0, len(o.order)+len(o.pending))
+	for _, hash := range o.order {
+		mu, err := o.store.Get(hash)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve mutation with hash %s: %w", hash, err)
+		}
+		mutations = append(mutations, mu)
+	}
+
+	pending := make([]string, 0, len(o.pending))
+	for hash := range o.pending {
+		pending = append(pending, hash)
+	}
+	slices.Sort(pending)
+	for _, hash := range pending {
+		mutations = append(mutations, o.pending[hash].mutation)
+	}
+
+	return mutations, nil
 }
 
+// ImportLog adds the mutations to the log and applies them. A mutation that
+// is rejected does not stop the import. The returned error joins a
+// *MutationError for every rejected mutation and for every imported mutation
+// that still waits for its parents afterwards; the latter wrap
+// ErrUnknownParent and are applied once the parents are imported. A
+// checkpoint that covers mutations neither the map nor a replica that
+// acknowledged a version to it has applied is rejected with
+// ErrInvalidOperation, so acknowledge the version of the replica the
+// mutations come from first.
 func (o *ORSetMap) ImportLog(mutations []Mutation) error {
 	o.mu.Lock()
-	defer o.mu.Unlock()
+	defer o.unlock()
+
+	return o.importEach(len(mutations), func(i int) (string, error) {
+		return o.importMutation(mutations[i])
+	})
+}
 
-	for _, mu := range mutations {
-		o.appendMutation(mu)
+// importEach imports n mutations with fn and reports the rejected ones and
+// those that wait for parents like ImportLog.
+func (o *ORSetMap) importEach(n int, fn func(i int) (string, error)) error {
+	var errs []error
+	imported := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		hash, err := fn(i)
+		if err != nil {
+			errs = append(errs, &MutationError{Index: i, Hash: hash, Err: err})
+			continue
+		}
+		imported[hash] = i
 	}
 
-	return nil
+	if len(o.pending) > 0 {
+		var parked []*MutationError
+		for hash, i := range imported {
+			if _, exists := o.pending[hash]; exists {
+				parked = append(parked, &MutationError{Index: i, Hash: hash, Err: ErrUnknownParent})
+			}
+		}
+		slices.SortFunc(parked, func(a, b *MutationError) int { return a.Index - b.Index })
+		for _, err := range parked {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
@@ -2,10 +2,10 @@ package crdt
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"testing"
 
-	"github.com/dominikbraun/graph"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -107,9 +107,10 @@ func TestORSetMapmutations(t *testing.T) {
 						Type:  AddOperation,
 						Key:   "fruit",
 						Value: scalar.New("banana"),
-						Tags:  map[Tag]bool{{Sequence: 1}: true},
+						Tags:  map[Tag]bool{{ReplicaID: "b", Sequence: 1}: true},
 					}},
 					Parents: []string{"unknown"},
+					Origin:  Tag{ReplicaID: "b", Sequence: 1},
 				})
 			},
 			wantContains: map[string]bool{
@@ -139,7 +140,7 @@ func TestORSetMapmutations(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			orsetMap := NewORSetMap()
+			orsetMap := must(NewORSetMap())
 			tc.mutations(orsetMap)
 			for key, expected := range tc.wantContains {
 				assert.Equal(t, expected, orsetMap.Contains(key), "Check Contains for key "+key)
@@ -154,7 +155,7 @@ func TestORSetMapmutations(t *testing.T) {
 				log, err := orsetMap.ExportLog()
 				require.NoError(t, err)
 
-				anotherORSetMap := NewORSetMap()
+				anotherORSetMap := must(NewORSetMap())
 				anotherORSetMap.ImportLog(log)
 				for key, expected := range tc.wantContains {
 					assert.Equal(t, expected, anotherORSetMap.Contains(key), "Replicated Check Contains for key "+key)
@@ -328,12 +329,12 @@ func TestORSetMapReplication(t *testing.T) {
 
 	for _, tt := range tests {
 		// Create a new ORSetMap for each test case
-		orsetMap := NewORSetMap()
+		orsetMap := must(NewORSetMap())
 
 		// Generate and apply mutations
 		mutations := tt.mutations()
 		for _, mu := range mutations {
-			orsetMap.applyMutation(mu)
+			require.NoError(t, orsetMap.applyMutation(mu))
 		}
 
 		// Verify the results
@@ -342,29 +343,368 @@ func TestORSetMapReplication(t *testing.T) {
 	}
 }
 
+func TestORSetMapConvergence(t *testing.T) {
+	// sync imports the logs of all given replicas into dst in order.
+	sync := func(t *testing.T, dst *ORSetMap, srcs ...*ORSetMap) {
+		for _, src := range srcs {
+			log, err := src.ExportLog()
+			require.NoError(t, err)
+			require.NoError(t, dst.ImportLog(log))
+		}
+	}
+
+	tests := []struct {
+		name      string
+		mutations func(t *testing.T, a, b *ORSetMap)
+		expected  map[string]Value
+	}{
+		{
+			name: "Concurrent first adds on the same key",
+			mutations: func(t *testing.T, a, b *ORSetMap) {
+				a.Add("title", scalar.New("from a"))
+				b.Add("title", scalar.New("from b"))
+			},
+			// both tags have sequence 1, the greater replica ID wins
+			expected: map[string]Value{
+				"title": scalar.New("from b"),
+			},
+		},
+		{
+			name: "Concurrent adds on different keys",
+			mutations: func(t *testing.T, a, b *ORSetMap) {
+				a.Add("title", scalar.New("title"))
+				b.Add("body", scalar.New("body"))
+			},
+			expected: map[string]Value{
+				"title": scalar.New("title"),
+				"body":  scalar.New("body"),
+			},
+		},
+		{
+			name: "Causally later add wins regardless of replica ID",
+			mutations: func(t *testing.T, a, b *ORSetMap) {
+				b.Add("title", scalar.New("from b"))
+				sync(t, a, b)
+				a.Add("title", scalar.New("from a"))
+			},
+			expected: map[string]Value{
+				"title": scalar.New("from a"),
+			},
+		},
+		{
+			name: "Concurrent add and remove on the same key",
+			mutations: func(t *testing.T, a, b *ORSetMap) {
+				a.Add("title", scalar.New("first"))
+				sync(t, b, a)
+				a.Add("title", scalar.New("second"))
+				b.Remove("title")
+			},
+			// the remove only observed the first add
+			expected: map[string]Value{
+				"title": scalar.New("second"),
+			},
+		},
+		{
+			name: "Concurrent removes of an observed add",
+			mutations: func(t *testing.T, a, b *ORSetMap) {
+				a.Add("title", scalar.New("first"))
+				sync(t, b, a)
+				a.Remove("title")
+				b.Remove("title")
+			},
+			expected: map[string]Value{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := must(NewORSetMap(WithReplicaID("a")))
+			b := must(NewORSetMap(WithReplicaID("b")))
+			tc.mutations(t, a, b)
+
+			ab := must(NewORSetMap(WithReplicaID("ab")))
+			sync(t, ab, a, b)
+			ba := must(NewORSetMap(WithReplicaID("ba")))
+			sync(t, ba, b, a)
+			sync(t, a, b)
+			sync(t, b, a)
+
+			for _, replica := range []*ORSetMap{a, b, ab, ba} {
+				assert.Equal(t, tc.expected, replica.List(), "replica "+replica.ReplicaID())
+				assert.Equal(t, Complete, replica.State(), "replica "+replica.ReplicaID())
+			}
+		})
+	}
+}
+
+func TestTagLess(t *testing.T) {
+	assert.True(t, Tag{ReplicaID: "b", Sequence: 1}.Less(Tag{ReplicaID: "a", Sequence: 2}))
+	assert.True(t, Tag{ReplicaID: "a", Sequence: 1}.Less(Tag{ReplicaID: "b", Sequence: 1}))
+	assert.False(t, Tag{ReplicaID: "a", Sequence: 1}.Less(Tag{ReplicaID: "a", Sequence: 1}))
+}
+
+func TestNewORSetMapReplicaID(t *testing.T) {
+	assert.Equal(t, "a", must(NewORSetMap(WithReplicaID("a"))).ReplicaID())
+	assert.NotEmpty(t, must(NewORSetMap()).ReplicaID())
+	assert.NotEqual(t, must(NewORSetMap()).ReplicaID(), must(NewORSetMap()).ReplicaID())
+}
+
+func TestORSetMapOutOfOrderImport(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	a.Add("title", scalar.New("draft"))
+	a.Add("body", scalar.New("text"))
+	logA, err := a.ExportLog()
+	require.NoError(t, err)
+	require.NoError(t, b.ImportLog(logA))
+	b.Add("title", scalar.New("final"))
+	a.Remove("body")
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	// the merge mutation has two parents
+	b.Add("tags", scalar.New("news"))
+
+	log, err := b.ExportLog()
+	require.NoError(t, err)
+	require.Len(t, log, 5)
+
+	tests := []struct {
+		name  string
+		order []int
+	}{
+		{name: "Reversed", order: []int{4, 3, 2, 1, 0}},
+		{name: "Merge before its parents", order: []int{4, 0, 2, 1, 3}},
+		{name: "Interleaved", order: []int{1, 3, 0, 4, 2}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			orsetMap := must(NewORSetMap())
+			for i, idx := range tc.order {
+				if err := orsetMap.ImportLog([]Mutation{log[idx]}); err != nil {
+					require.ErrorIs(t, err, ErrUnknownParent)
+				}
+				if i == 0 {
+					assert.Equal(t, Partial, orsetMap.State())
+				}
+			}
+
+			assert.Equal(t, Complete, orsetMap.State())
+			assert.Equal(t, b.List(), orsetMap.List())
+			assert.Equal(t, map[string]Value{
+				"title": scalar.New("final"),
+				"tags":  scalar.New("news"),
+			}, orsetMap.List())
+
+			leaves := orsetMap.getLeaves()
+			assert.Equal(t, []string{must(HashMutation(log[4]))}, leaves)
+
+			// the exported log is in causal order again
+			exported, err := orsetMap.ExportLog()
+			require.NoError(t, err)
+			applied := map[string]bool{}
+			for _, mu := range exported {
+				for _, parent := range mu.Parents {
+					assert.True(t, applied[parent], "parent exported before child")
+				}
+				applied[must(HashMutation(mu))] = true
+			}
+		})
+	}
+}
+
+func TestORSetMapMissingParents(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	for i := 0; i < 6; i++ {
+		a.Add("key", scalar.New(int64(i)))
+	}
+	log, err := a.ExportLog()
+	require.NoError(t, err)
+	hashes := make([]string, len(log))
+	for i, mu := range log {
+		hashes[i] = must(HashMutation(mu))
+	}
+
+	var applied []string
+	b := must(NewORSetMap(WithReplicaID("b"), WithPendingAppliedHandler(func(hash string) {
+		applied = append(applied, hash)
+	})))
+	assert.Empty(t, b.MissingParents())
+	assert.Empty(t, b.PendingMutations())
+
+	steps := []struct {
+		index       int
+		wantMissing []int
+		wantPending []int
+		wantApplied []int
+	}{
+		{index: 5, wantMissing: []int{4}, wantPending: []int{5}},
+		{index: 2, wantMissing: []int{1, 4}, wantPending: []int{2, 5}},
+		{index: 4, wantMissing: []int{1, 3}, wantPending: []int{2, 4, 5}},
+		{index: 0, wantMissing: []int{1, 3}, wantPending: []int{2, 4, 5}},
+		{index: 3, wantMissing: []int{1}, wantPending: []int{2, 3, 4, 5}},
+		// the last missing ancestor releases the whole chain in causal order
+		{index: 1, wantApplied: []int{2, 3, 4, 5}},
+	}
+	for _, step := range steps {
+		err := b.ImportLog([]Mutation{log[step.index]})
+		if slices.Contains(step.wantPending, step.index) {
+			var mutationErr *MutationError
+			require.ErrorAs(t, err, &mutationErr)
+			assert.ErrorIs(t, err, ErrUnknownParent)
+			assert.Equal(t, hashes[step.index], mutationErr.Hash)
+		} else {
+			require.NoError(t, err)
+		}
+
+		pick := func(indexes []int) []string {
+			picked := []string{}
+			for _, i := range indexes {
+				picked = append(picked, hashes[i])
+			}
+			slices.Sort(picked)
+			return picked
+		}
+		assert.Equal(t, pick(step.wantMissing), b.MissingParents(), "missing after importing %d", step.index)
+		assert.Equal(t, pick(step.wantPending), b.PendingMutations(), "pending after importing %d", step.index)
+	}
+
+	assert.Equal(t, []string{hashes[2], hashes[3], hashes[4], hashes[5]}, applied)
+	assert.Equal(t, Complete, b.State())
+	assert.Equal(t, a.List(), b.List())
+}
+
+func TestORSetMapFoldedPendingMutation(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	for i := 1; i <= 3; i++ {
+		must(a.Add(fmt.Sprintf("k%d", i), scalar.New(int64(i))))
+	}
+	log := must(a.ExportLog())
+	hashes := make([]string, len(log))
+	var ops []*Operation
+	for i, mu := range log {
+		hashes[i] = must(HashMutation(mu))
+		ops = append(ops, mu.Operations...)
+	}
+
+	var applied []string
+	b := must(NewORSetMap(WithReplicaID("b"), WithPendingAppliedHandler(func(hash string) {
+		applied = append(applied, hash)
+	})))
+	b.Acknowledge("a", a.Version())
+	assert.ErrorIs(t, b.ImportLog(log[1:]), ErrUnknownParent)
+
+	// a checkpoint that folds the parked mutations and names the first one,
+	// as another replica built on it, releases the second one only to drop it
+	cp := Mutation{
+		Parents:    []string{hashes[0], hashes[2]},
+		Origin:     Tag{ReplicaID: "c", Sequence: 4},
+		Checkpoint: a.Version(),
+		Operations: ops,
+	}
+	require.NoError(t, b.ImportLog([]Mutation{cp}))
+	assert.Empty(t, applied)
+	assert.Empty(t, b.PendingMutations())
+	assert.Equal(t, a.List(), b.List())
+}
+
+func TestORSetMapAddRemoveHash(t *testing.T) {
+	orsetMap := must(NewORSetMap(WithReplicaID("a")))
+
+	hash, err := orsetMap.Add("title", scalar.New("draft"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{hash}, orsetMap.Heads())
+
+	hash, err = orsetMap.Remove("title")
+	require.NoError(t, err)
+	assert.Equal(t, []string{hash}, orsetMap.Heads())
+
+	// a rejected mutation leaves the map untouched
+	_, err = orsetMap.Add("title", nil)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	assert.Equal(t, []string{hash}, orsetMap.Heads())
+	assert.Equal(t, Version{"a": 2}, orsetMap.Version())
+
+	_, err = orsetMap.Add("title", scalar.New("final"))
+	require.NoError(t, err)
+	assert.Equal(t, Version{"a": 3}, orsetMap.Version())
+}
+
+func TestORSetMapImportLogErrors(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	for i := 0; i < 3; i++ {
+		must(a.Add("key", scalar.New(int64(i))))
+	}
+	log, err := a.ExportLog()
+	require.NoError(t, err)
+
+	invalid := NewMutation(&Operation{Type: AddOperation, Key: "key", Tags: Tags{{ReplicaID: "x", Sequence: 1}: true}})
+	orsetMap := must(NewORSetMap(WithReplicaID("b")))
+	err = orsetMap.ImportLog([]Mutation{log[0], invalid, log[2]})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidOperation)
+	assert.ErrorIs(t, err, ErrUnknownParent)
+
+	// every failed mutation is reported with its index
+	var indexes []int
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var mutationErr *MutationError
+		require.ErrorAs(t, err, &mutationErr)
+		indexes = append(indexes, mutationErr.Index)
+	}
+	assert.Equal(t, []int{1, 2}, indexes)
+
+	// the valid mutations are kept and the parked one applies with its parent
+	assert.Equal(t, Partial, orsetMap.State())
+	require.NoError(t, orsetMap.ImportLog(log[1:2]))
+	assert.Equal(t, Complete, orsetMap.State())
+	assert.Equal(t, a.List(), orsetMap.List())
+}
+
+func TestORSetMapCycle(t *testing.T) {
+	add := func(value string, parents ...string) Mutation {
+		mu := NewMutation(&Operation{
+			Type:  AddOperation,
+			Key:   "key",
+			Value: scalar.New(value),
+			Tags:  Tags{{ReplicaID: "b", Sequence: 1}: true},
+		})
+		mu.Parents = parents
+		mu.Origin = Tag{ReplicaID: "b", Sequence: 1}
+		return mu
+	}
+
+	orsetMap := newTestORSetMap()
+	err := orsetMap.ImportLog([]Mutation{add("a", "add-a")})
+	assert.ErrorIs(t, err, ErrCycle)
+	assert.False(t, orsetMap.Has("add-a"))
+
+	// b waits for c, which in turn names b as its parent
+	err = orsetMap.ImportLog([]Mutation{add("b", "add-c"), add("c", "add-b")})
+	assert.ErrorIs(t, err, ErrCycle)
+	assert.ErrorIs(t, err, ErrUnknownParent)
+	assert.True(t, orsetMap.Has("add-b"))
+	assert.False(t, orsetMap.Has("add-c"))
+	assert.Equal(t, []string{"add-c"}, orsetMap.MissingParents())
+}
+
 func TestGetLeaves(t *testing.T) {
 	orsetMap := newTestORSetMap()
 	orsetMap.Add("fruit", scalar.New("apple"))
 	orsetMap.Add("fruit", scalar.New("banana"))
 	orsetMap.Add("vegetable", scalar.New("carrot"))
 
-	leaves, err := orsetMap.getLeaves()
-	assert.NoError(t, err)
-
+	leaves := orsetMap.getLeaves()
 	assert.Equal(t, 1, len(leaves))
 	assert.Equal(t, "add-carrot", leaves[0])
 }
 
-// newTestORSetMap creates a new ORSetMap instance, overriding the log
-// with a new directed acyclic graph that uses a test hash function.
+// newTestORSetMap creates a new ORSetMap instance that uses a test hash
+// function.
 func newTestORSetMap() *ORSetMap {
-	orsetMap := NewORSetMap()
-	orsetMap.hasher = testHashMutation
-	orsetMap.log = graph.New(
-		testHashMutation,
-		graph.Directed(),
-		graph.Acyclic(),
-	)
+	orsetMap := must(NewORSetMap())
+	orsetMap.hasher = func(mu Mutation) (string, error) {
+		return testHashMutation(mu), nil
+	}
 	return orsetMap
 }
 
@@ -402,7 +742,7 @@ func BenchmarkORSetMap(b *testing.B) {
 	}
 
 	b.Run("Add", func(b *testing.B) {
-		orSetMap := NewORSetMap()
+		orSetMap := must(NewORSetMap())
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
 			orSetMap.Add(keys[i%numKeys], scalar.New("v1"))
@@ -410,7 +750,7 @@ func BenchmarkORSetMap(b *testing.B) {
 	})
 
 	b.Run("Get", func(b *testing.B) {
-		orSetMap := NewORSetMap()
+		orSetMap := must(NewORSetMap())
 		// Prepopulate the map with values
 		for i := 0; i < numKeys; i++ {
 			orSetMap.Add(keys[i], scalar.New("v1"))
@@ -422,7 +762,7 @@ func BenchmarkORSetMap(b *testing.B) {
 	})
 
 	b.Run("Remove", func(b *testing.B) {
-		orSetMap := NewORSetMap()
+		orSetMap := must(NewORSetMap())
 		// Prepopulate the map with values
 		for i := 0; i < numKeys; i++ {
 			orSetMap.Add(keys[i], scalar.New("v1"))
@@ -434,6 +774,31 @@ func BenchmarkORSetMap(b *testing.B) {
 	})
 }
 
+// BenchmarkORSetMapAddLogSize measures Add on maps whose log already holds
+// the given number of mutations. The cost should not grow with the log.
+func BenchmarkORSetMapAddLogSize(b *testing.B) {
+	for _, size := range []int{1_000, 10_000, 100_000} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			orSetMap := must(NewORSetMap())
+			for i := 0; i < size; i++ {
+				orSetMap.Add("key"+strconv.Itoa(i%100), scalar.New("v1"))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				orSetMap.Add("key"+strconv.Itoa(i%100), scalar.New("v2"))
+			}
+		})
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
 func assume[T scalar.ScalarValue](v Value) T {
 	s, ok := v.(*scalar.Scalar[T])
 	if !ok {
@@ -0,0 +1,235 @@
+package crdt
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// Resolver picks the value of a key from its live values, which hold more
+// than one value while concurrent writes conflict. The values are sorted
+// greatest tag first and are never empty. A resolver must only depend on its
+// arguments, and every replica of a map must use the same resolvers, so that
+// replicas holding the same values resolve them identically.
+type Resolver interface {
+	Resolve(key string, values []Versioned) Value
+}
+
+// ResolverFunc is a function that implements Resolver, for custom merges.
+type ResolverFunc func(key string, values []Versioned) Value
+
+func (f ResolverFunc) Resolve(key string, values []Versioned) Value {
+	return f(key, values)
+}
+
+var (
+	// GreatestTag picks the value with the greatest tag, like
+	// KeyValue.Resolve. It is the default resolver.
+	GreatestTag Resolver = ResolverFunc(greatestTag)
+	// LastWriterWins picks the value written last, by the hybrid logical
+	// clock timestamp of its write. Ties are broken by the greatest tag.
+	LastWriterWins Resolver = ResolverFunc(lastWriterWins)
+	// MaxValue picks the greatest number. Values that are not numbers only
+	// win if there are no numbers, by the greatest tag.
+	MaxValue Resolver = ResolverFunc(func(_ string, values []Versioned) Value {
+		return extremeNumber(values, 1)
+	})
+	// MinValue picks the smallest number. Values that are not numbers only
+	// win if there are no numbers, by the greatest tag.
+	MinValue Resolver = ResolverFunc(func(_ string, values []Versioned) Value {
+		return extremeNumber(values, -1)
+	})
+	// UnionBytes merges byte slices by a bitwise OR, shorter slices being
+	// padded with zeros, so that bits set by any writer stay set. Values that
+	// are not byte slices only win if there are none, by the greatest tag.
+	UnionBytes Resolver = ResolverFunc(unionBytes)
+)
+
+// WithResolver sets the resolver of the keys that no resolver set with
+// WithKeyResolver applies to.
+func WithResolver(r Resolver) Option {
+	return func(o *ORSetMap) {
+		o.resolver = r
+	}
+}
+
+// WithKeyResolver sets the resolver of the keys with the given prefix. If
+// several prefixes match a key, the longest one applies.
+func WithKeyResolver(prefix string, r Resolver) Option {
+	return func(o *ORSetMap) {
+		if o.keyResolvers == nil {
+			o.keyResolvers = make(map[string]Resolver)
+		}
+		o.keyResolvers[prefix] = r
+	}
+}
+
+// resolve returns the value of the key with the resolver configured for it.
+func (o *ORSetMap) resolve(key string, elem *KeyValue) Value {
+	r := o.resolverFor(key)
+	if r == nil {
+		return elem.Resolve()
+	}
+	values := elem.versioned()
+	if len(values) == 0 {
+		return nil
+	}
+
+	return r.Resolve(key, values)
+}
+
+func (o *ORSetMap) resolverFor(key string) Resolver {
+	if r, found := longestPrefix(o.keyResolvers, key); found {
+		return r
+	}
+
+	return o.resolver
+}
+
+func greatestTag(_ string, values []Versioned) Value {
+	return values[0].Value
+}
+
+func lastWriterWins(_ string, values []Versioned) Value {
+	last := values[0]
+	for _, v := range values[1:] {
+		if v.Timestamp.Compare(last.Timestamp) > 0 {
+			last = v
+		}
+	}
+
+	return last.Value
+}
+
+// extremeNumber returns the greatest number for sign 1 and the smallest for
+// sign -1.
+func extremeNumber(values []Versioned, sign int) Value {
+	var extreme Value
+	for _, v := range values {
+		if !isNumber(v.Value) {
+			continue
+		}
+		if extreme == nil || sign*compareNumbers(v.Value, extreme) > 0 {
+			extreme = v.Value
+		}
+	}
+	if extreme == nil {
+		return values[0].Value
+	}
+
+	return extreme
+}
+
+func isNumber(v Value) bool {
+	switch v.Type() {
+	case scalar.Int64, scalar.Uint64, scalar.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// compareNumbers compares two numbers. Integers of the same kind are compared
+// exactly; floats are compared with integers as floats.
+func compareNumbers(a, b Value) int {
+	ai, aInt := a.Int64()
+	bi, bInt := b.Int64()
+	au, aUint := a.Uint64()
+	bu, bUint := b.Uint64()
+	switch {
+	case aInt && bInt:
+		return cmp.Compare(ai, bi)
+	case aUint && bUint:
+		return cmp.Compare(au, bu)
+	case aInt && bUint:
+		if ai < 0 {
+			return -1
+		}
+		return cmp.Compare(uint64(ai), bu)
+	case aUint && bInt:
+		if bi < 0 {
+			return 1
+		}
+		return cmp.Compare(au, uint64(bi))
+	}
+
+	return cmp.Compare(toFloat(a), toFloat(b))
+}
+
+func toFloat(v Value) float64 {
+	if i, ok := v.Int64(); ok {
+		return float64(i)
+	}
+	if u, ok := v.Uint64(); ok {
+		return float64(u)
+	}
+	f, _ := v.Float64()
+
+	return f
+}
+
+func unionBytes(_ string, values []Versioned) Value {
+	var union []byte
+	var found bool
+	for _, v := range values {
+		b, ok := v.Value.ByteSlice()
+		if !ok {
+			continue
+		}
+		found = true
+		if len(b) > len(union) {
+			union = append(union, make([]byte, len(b)-len(union))...)
+		}
+		for i := range b {
+			union[i] |= b[i]
+		}
+	}
+	if !found {
+		return values[0].Value
+	}
+	if union == nil {
+		union = []byte{}
+	}
+
+	return scalar.New(union)
+}
+
+// versioned returns the live values of the key, greatest tag first.
+func (kv *KeyValue) versioned() []Versioned {
+	values := make([]Versioned, 0, len(kv.liveTags()))
+	for tag := range kv.liveTags() {
+		detail := kv.Tags[tag]
+		values = append(values, Versioned{
+			Value:     detail.Value,
+			Tag:       tag,
+			Owner:     detail.Owner,
+			Hash:      detail.Hash,
+			Time:      detail.Time,
+			Timestamp: detail.Timestamp,
+		})
+	}
+	slices.SortFunc(values, func(a, b Versioned) int {
+		return compareTags(b.Tag, a.Tag)
+	})
+
+	return values
+}
+
+// equalValues reports whether two values, either of which may be nil, are
+// equal as defined by scalar.Any.Equal.
+func equalValues(a, b Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	x, err := ToAny(a)
+	if err != nil {
+		return false
+	}
+	y, err := ToAny(b)
+	if err != nil {
+		return false
+	}
+
+	return x.Equal(y)
+}
@@ -0,0 +1,187 @@
+package crdt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// concurrentWrite returns a mutation of replica that sets key without having
+// observed any other write, stamped with the given time unless it is zero.
+func concurrentWrite(replica string, seq uint64, key string, value Value, at time.Time) Mutation {
+	origin := Tag{ReplicaID: replica, Sequence: seq}
+	var ts Timestamp
+	if !at.IsZero() {
+		ts = Timestamp{Wall: at.UnixNano()}
+	}
+	return Mutation{
+		Operations: []*Operation{{
+			Type:      AddOperation,
+			Key:       key,
+			Value:     value,
+			Tags:      Tags{origin: true},
+			Time:      at,
+			Timestamp: ts,
+		}},
+		Origin: origin,
+	}
+}
+
+func TestResolvers(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	joinStrings := ResolverFunc(func(_ string, values []Versioned) Value {
+		s := make([]string, 0, len(values))
+		for _, v := range values {
+			str, _ := v.Value.String()
+			s = append(s, str)
+		}
+		return scalar.New(strings.Join(s, "+"))
+	})
+
+	tests := []struct {
+		name     string
+		resolver Resolver
+		writes   []Mutation
+		want     Value
+	}{
+		{
+			name:     "Greatest tag",
+			resolver: GreatestTag,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New("a"), t0.Add(time.Hour)),
+				concurrentWrite("b", 2, "k", scalar.New("b"), t0),
+				concurrentWrite("c", 2, "k", scalar.New("c"), t0),
+			},
+			want: scalar.New("c"),
+		},
+		{
+			name:     "Last writer wins",
+			resolver: LastWriterWins,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New("a"), t0.Add(time.Hour)),
+				concurrentWrite("b", 2, "k", scalar.New("b"), t0),
+				concurrentWrite("c", 2, "k", scalar.New("c"), t0),
+			},
+			want: scalar.New("a"),
+		},
+		{
+			name:     "Last writer wins tie",
+			resolver: LastWriterWins,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New("a"), t0),
+				concurrentWrite("b", 1, "k", scalar.New("b"), t0),
+			},
+			want: scalar.New("b"),
+		},
+		{
+			name:     "Max value",
+			resolver: MaxValue,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New(int64(-3)), t0),
+				concurrentWrite("b", 1, "k", scalar.New(uint64(7)), t0),
+				concurrentWrite("c", 1, "k", scalar.New(6.5), t0),
+				concurrentWrite("d", 1, "k", scalar.New("not a number"), t0),
+			},
+			want: scalar.New(uint64(7)),
+		},
+		{
+			name:     "Min value",
+			resolver: MinValue,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New(int64(-3)), t0),
+				concurrentWrite("b", 1, "k", scalar.New(uint64(7)), t0),
+				concurrentWrite("c", 1, "k", scalar.New(-6.5), t0),
+				concurrentWrite("d", 1, "k", scalar.New("not a number"), t0),
+			},
+			want: scalar.New(-6.5),
+		},
+		{
+			name:     "Max value without numbers",
+			resolver: MaxValue,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New("a"), t0),
+				concurrentWrite("b", 1, "k", scalar.New("b"), t0),
+			},
+			want: scalar.New("b"),
+		},
+		{
+			name:     "Union bytes",
+			resolver: UnionBytes,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New([]byte{0b0001}), t0),
+				concurrentWrite("b", 1, "k", scalar.New([]byte{0b0100, 0b1000}), t0),
+				concurrentWrite("c", 1, "k", scalar.New(true), t0),
+			},
+			want: scalar.New([]byte{0b0101, 0b1000}),
+		},
+		{
+			name:     "Custom",
+			resolver: joinStrings,
+			writes: []Mutation{
+				concurrentWrite("a", 1, "k", scalar.New("a"), t0),
+				concurrentWrite("b", 1, "k", scalar.New("b"), t0),
+				concurrentWrite("c", 1, "k", scalar.New("c"), t0),
+			},
+			want: scalar.New("c+b+a"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// every replica imports the writes in a different order
+			for shift := range tc.writes {
+				o := must(NewORSetMap(WithReplicaID(fmt.Sprint("r", shift)), WithResolver(tc.resolver)))
+				for i := range tc.writes {
+					require.NoError(t, o.ImportLog([]Mutation{tc.writes[(i+shift)%len(tc.writes)]}))
+				}
+				assert.Equal(t, tc.want, o.Get("k"), "order %d", shift)
+				assert.Equal(t, map[string]Value{"k": tc.want}, o.List(), "order %d", shift)
+			}
+		})
+	}
+}
+
+func TestKeyResolver(t *testing.T) {
+	o := must(NewORSetMap(
+		WithResolver(MinValue),
+		WithKeyResolver("count/", MaxValue),
+		WithKeyResolver("count/exact", GreatestTag),
+	))
+	for i, key := range []string{"count/a", "count/exact", "other"} {
+		seq := uint64(i + 1)
+		require.NoError(t, o.ImportLog([]Mutation{
+			concurrentWrite("a", seq, key, scalar.New(int64(5)), time.Time{}),
+			concurrentWrite("b", seq, key, scalar.New(int64(1)), time.Time{}),
+			concurrentWrite("c", seq, key, scalar.New(int64(3)), time.Time{}),
+		}))
+	}
+
+	assert.Equal(t, map[string]Value{
+		"count/a":     scalar.New(int64(5)),
+		"count/exact": scalar.New(int64(3)),
+		"other":       scalar.New(int64(1)),
+	}, o.List())
+}
+
+func TestSubscribeResolver(t *testing.T) {
+	o := must(NewORSetMap(WithResolver(MaxValue)))
+	ch, cancel := o.Subscribe(nil)
+	defer cancel()
+
+	high := concurrentWrite("a", 1, "k", scalar.New(int64(5)), time.Time{})
+	low := concurrentWrite("b", 2, "k", scalar.New(int64(1)), time.Time{})
+	higher := concurrentWrite("c", 1, "k", scalar.New(int64(9)), time.Time{})
+	require.NoError(t, o.ImportLog([]Mutation{high, low, higher}))
+
+	// the lower value does not change the resolved value
+	assert.Equal(t, []Change{
+		{Key: "k", New: scalar.New(int64(5)), Hash: must(HashMutation(high))},
+		{Key: "k", Old: scalar.New(int64(5)), New: scalar.New(int64(9)), Hash: must(HashMutation(higher))},
+	}, receive(t, ch, 2))
+}
@@ -0,0 +1,137 @@
+package crdt
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidSignature is returned for a mutation that is not signed by
+	// the private key of its owner.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrUnknownOwner is returned by a Keyring for an owner it holds no key
+	// for.
+	ErrUnknownOwner = errors.New("unknown owner")
+)
+
+// Keyring maps the owners of mutations to their ed25519 public keys.
+// Implementations must be safe for concurrent use.
+type Keyring interface {
+	// PublicKey returns the public key of the owner, or ErrUnknownOwner.
+	PublicKey(owner string) (ed25519.PublicKey, error)
+}
+
+// StaticKeyring is a Keyring holding a fixed set of keys, keyed by owner.
+type StaticKeyring map[string]ed25519.PublicKey
+
+func (k StaticKeyring) PublicKey(owner string) (ed25519.PublicKey, error) {
+	key, exists := k[owner]
+	if !exists {
+		return nil, ErrUnknownOwner
+	}
+
+	return key, nil
+}
+
+// WithKeyring sets the keyring that the signatures of imported mutations are
+// verified with. ImportLog and Restore then reject mutations that are not
+// signed by their owner. Mutations read from the store of the map are
+// trusted. A checkpoint restates the values of every owner, so a map with a
+// keyring rejects checkpoints unless WithAuthorizer allows them.
+func WithKeyring(keyring Keyring) Option {
+	return func(o *ORSetMap) {
+		o.keyring = keyring
+	}
+}
+
+// WithSigner sets the owner of the local mutations of the map and the key
+// they are signed with.
+func WithSigner(owner string, key ed25519.PrivateKey) Option {
+	return func(o *ORSetMap) {
+		o.owner = owner
+		o.signingKey = key
+	}
+}
+
+// SigningBytes returns the bytes a signature of the mutation covers: its
+// canonical encoding without the signature.
+func SigningBytes(mu Mutation) ([]byte, error) {
+	mu.Signature = nil
+
+	return EncodeMutation(mu)
+}
+
+// SignMutation returns the mutation with the given owner, signed with the
+// owner's private key. The signature covers the hashes of the parents, and
+// therefore the history the mutation was made on.
+func SignMutation(mu Mutation, owner string, key ed25519.PrivateKey) (Mutation, error) {
+	if owner == "" {
+		return Mutation{}, errors.New("failed to sign mutation: empty owner")
+	}
+	if len(key) != ed25519.PrivateKeySize {
+		return Mutation{}, fmt.Errorf("failed to sign mutation: private key has %d bytes", len(key))
+	}
+	mu.Owner = owner
+	b, err := SigningBytes(mu)
+	if err != nil {
+		return Mutation{}, fmt.Errorf("failed to encode mutation: %w", err)
+	}
+	mu.Signature = ed25519.Sign(key, b)
+
+	return mu, nil
+}
+
+// VerifyMutation checks that the mutation is signed by the key the keyring
+// holds for its owner.
+func VerifyMutation(mu Mutation, keyring Keyring) error {
+	if mu.Owner == "" {
+		return fmt.Errorf("%w: mutation has no owner", ErrInvalidSignature)
+	}
+	if len(mu.Signature) == 0 {
+		return fmt.Errorf("%w: mutation is not signed", ErrInvalidSignature)
+	}
+	key, err := keyring.PublicKey(mu.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to look up key of owner %s: %w", mu.Owner, err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: public key of owner %s has %d bytes", ErrInvalidSignature, mu.Owner, len(key))
+	}
+	b, err := SigningBytes(mu)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+	}
+	if !ed25519.Verify(key, b, mu.Signature) {
+		return fmt.Errorf("%w: signature does not match owner %s", ErrInvalidSignature, mu.Owner)
+	}
+
+	return nil
+}
+
+// sign signs a local mutation if the map has a signer.
+func (o *ORSetMap) sign(mu Mutation) (Mutation, error) {
+	if o.signingKey == nil {
+		return mu, nil
+	}
+
+	return SignMutation(mu, o.owner, o.signingKey)
+}
+
+// importMutation verifies the signature of a mutation received from another
+// replica, if the map has a keyring, and that a checkpoint covers no more
+// than is known to be applied, and appends it to the log.
+func (o *ORSetMap) importMutation(mu Mutation) (string, error) {
+	if o.keyring != nil {
+		if err := VerifyMutation(mu, o.keyring); err != nil {
+			hash, _ := o.hasher(mu)
+			return hash, err
+		}
+	}
+	if err := o.checkCheckpoint(mu); err != nil {
+		hash, _ := o.hasher(mu)
+		return hash, err
+	}
+
+	return o.appendMutation(mu)
+}
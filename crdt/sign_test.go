@@ -0,0 +1,128 @@
+package crdt
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// newKey returns a key pair generated from a fixed seed, so that signatures
+// are reproducible.
+func newKey(seed byte) (ed25519.PublicKey, ed25519.PrivateKey) {
+	key := ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), seed))
+
+	return key.Public().(ed25519.PublicKey), key
+}
+
+func TestVerifyMutation(t *testing.T) {
+	alicePub, alice := newKey(1)
+	bobPub, _ := newKey(2)
+	_, mallory := newKey(3)
+	keyring := StaticKeyring{"alice": alicePub, "bob": bobPub}
+
+	mu := Mutation{
+		Operations: []*Operation{{Key: "k", Value: scalar.New("v"), Tags: Tags{{ReplicaID: "a", Sequence: 1}: true}}},
+		Origin:     Tag{ReplicaID: "a", Sequence: 1},
+	}
+	signed := must(SignMutation(mu, "alice", alice))
+	require.NoError(t, VerifyMutation(signed, keyring))
+	assert.Equal(t, "alice", signed.Owner)
+	assert.Empty(t, mu.Owner, "the mutation is signed as a copy")
+
+	tampered := must(SignMutation(mu, "alice", alice))
+	tampered.Origin = Tag{ReplicaID: "a", Sequence: 2}
+	impersonated := must(SignMutation(mu, "alice", mallory))
+	reattributed := signed
+	reattributed.Owner = "bob"
+
+	tests := []struct {
+		name string
+		mu   Mutation
+		want error
+	}{
+		{name: "Unsigned", mu: mu, want: ErrInvalidSignature},
+		{name: "Tampered", mu: tampered, want: ErrInvalidSignature},
+		{name: "Signed with another key", mu: impersonated, want: ErrInvalidSignature},
+		{name: "Attributed to another owner", mu: reattributed, want: ErrInvalidSignature},
+		{name: "Unknown owner", mu: must(SignMutation(mu, "mallory", mallory)), want: ErrUnknownOwner},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, VerifyMutation(tc.mu, keyring), tc.want)
+		})
+	}
+
+	_, err := SignMutation(mu, "alice", alice[:10])
+	assert.Error(t, err)
+	_, err = SignMutation(mu, "", alice)
+	assert.Error(t, err)
+}
+
+func TestImportLogVerifiesSignatures(t *testing.T) {
+	alicePub, alice := newKey(1)
+	bobPub, bob := newKey(2)
+	_, mallory := newKey(3)
+	keyring := StaticKeyring{"alice": alicePub, "bob": bobPub}
+
+	checkpointers := WithAuthorizer(OwnerOnly("users/", "alice"))
+	a := must(NewORSetMap(WithReplicaID("a"), WithSigner("alice", alice), WithKeyring(keyring), checkpointers))
+	b := must(NewORSetMap(WithReplicaID("b"), WithSigner("bob", bob), WithKeyring(keyring), checkpointers))
+	must(a.Add("title", scalar.New("a")))
+	must(b.Add("body", scalar.New("b")))
+	exchange(t, a, b)
+	assert.Equal(t, a.List(), b.List())
+	assert.Equal(t, "alice", b.GetAll("title")[0].Owner)
+	assert.Equal(t, "bob", a.GetAll("body")[0].Owner)
+
+	// a forged edit attributed to alice is rejected, an honest one is not
+	forged := must(SignMutation(Mutation{
+		Operations: []*Operation{{Key: "title", Value: scalar.New("forged"), Tags: Tags{{ReplicaID: "m", Sequence: 9}: true}}},
+		Parents:    a.Heads(),
+		Origin:     Tag{ReplicaID: "m", Sequence: 9},
+	}, "alice", mallory))
+	unsigned := forged
+	unsigned.Owner, unsigned.Signature, unsigned.Parents = "", nil, nil
+	honest := must(b.Add("body", scalar.New("edited")))
+	mutation, _ := b.Mutation(honest)
+
+	err := a.ImportLog([]Mutation{forged, unsigned, mutation})
+	var mutationErr *MutationError
+	require.ErrorAs(t, err, &mutationErr)
+	assert.Equal(t, 0, mutationErr.Index)
+	assert.Equal(t, must(HashMutation(forged)), mutationErr.Hash)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	assert.False(t, a.Has(must(HashMutation(forged))))
+	assert.False(t, a.Has(must(HashMutation(unsigned))))
+	assert.Equal(t, scalar.New("a"), a.Get("title"))
+	assert.Equal(t, scalar.New("edited"), a.Get("body"))
+
+	// checkpoints are signed by the replica that made them and keep the
+	// owners of the values they carry
+	exchange(t, a, b)
+	cp := must(a.Checkpoint())
+	require.NotEmpty(t, cp)
+	log := must(a.ExportLog())
+	require.NoError(t, b.ImportLog(log))
+	assert.True(t, b.Has(cp))
+	fresh := must(NewORSetMap(WithReplicaID("c"), WithKeyring(keyring), checkpointers))
+	fresh.Acknowledge(a.ReplicaID(), a.Version())
+	require.NoError(t, fresh.ImportLog(log))
+	assert.Equal(t, a.List(), fresh.List())
+	assert.Equal(t, "alice", fresh.GetAll("title")[0].Owner)
+	assert.Equal(t, "bob", fresh.GetAll("body")[0].Owner)
+
+	// without an authorizer, a map with a keyring trusts no checkpoint
+	untrusting := must(NewORSetMap(WithReplicaID("d"), WithKeyring(keyring)))
+	untrusting.Acknowledge(a.ReplicaID(), a.Version())
+	assert.ErrorIs(t, untrusting.ImportLog(log), ErrUnauthorized)
+	assert.Empty(t, untrusting.List())
+
+	// a map without a keyring accepts anything
+	unverified := must(NewORSetMap())
+	require.NoError(t, unverified.ImportLog([]Mutation{unsigned}))
+}
@@ -0,0 +1,380 @@
+package crdt
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// SnapshotVersion is the version of the snapshot encoding produced by
+// MarshalSnapshot. It is the first byte of every encoded snapshot. Like
+// mutations, snapshots are encoded as ascending fields that are omitted when
+// empty.
+const SnapshotVersion = 1
+
+// Field numbers of Snapshot.
+const (
+	snapshotFieldHeads = iota + 1
+	snapshotFieldApplied
+	snapshotFieldVersion
+	snapshotFieldSequence
+	snapshotFieldElements
+	snapshotFieldCheckpoint
+	snapshotFieldAliases
+)
+
+// Snapshot is the state of an ORSetMap after applying the mutations up to
+// and including its heads. Mutations waiting for parents are not part of it.
+type Snapshot struct {
+	Heads      []string // heads of the covered mutations, sorted; identify the snapshot
+	Applied    []string // covered mutations in causal order
+	Version    Version
+	Sequence   uint64
+	Elements   map[string]*KeyValue
+	Checkpoint Version  // version of the applied checkpoints
+	Aliases    []string // folded mutations still named as parents, sorted
+}
+
+// Snapshot returns the state of the applied mutations of the map.
+func (o *ORSetMap) Snapshot() *Snapshot {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return &Snapshot{
+		Heads:      o.getLeaves(),
+		Applied:    slices.Clone(o.order),
+		Version:    maps.Clone(o.version),
+		Sequence:   o.sequence,
+		Elements:   cloneElements(o.elements),
+		Checkpoint: maps.Clone(o.checkpoint),
+		Aliases:    sortedKeys(o.aliases),
+	}
+}
+
+// Restore creates an ORSetMap from a snapshot instead of replaying the
+// mutations it covers. The mutations of the store that the snapshot does not
+// cover are applied next, followed by the tail. A restored map does not
+// serve the mutations covered by the snapshot unless its store holds them,
+// and At cannot view the states they lead to.
+func Restore(snapshot *Snapshot, tail []Mutation, opts ...Option) (*ORSetMap, error) {
+	o := newORSetMap(opts...)
+	for _, hash := range slices.Concat(snapshot.Aliases, snapshot.Applied) {
+		if err := o.log.AddVertex(hash); err != nil {
+			return nil, fmt.Errorf("failed to add mutation with hash %s: %w", hash, err)
+		}
+		o.mutations[hash] = true
+	}
+	for _, hash := range snapshot.Aliases {
+		o.aliases[hash] = struct{}{}
+	}
+	o.order = slices.Clone(snapshot.Applied)
+	for _, hash := range snapshot.Heads {
+		o.heads[hash] = struct{}{}
+	}
+	o.version = maps.Clone(snapshot.Version)
+	if o.version == nil {
+		o.version = make(Version)
+	}
+	for replica, seq := range snapshot.Checkpoint {
+		o.checkpoint[replica] = seq
+	}
+	o.sequence = snapshot.Sequence
+	o.elements = cloneElements(snapshot.Elements)
+	// local writes must order after the writes the snapshot holds
+	for _, elem := range o.elements {
+		for _, detail := range elem.Tags {
+			o.clock.observe(detail.Timestamp)
+		}
+	}
+	if len(o.order) > 0 {
+		o.state = Complete
+	}
+
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	for i, mu := range tail {
+		if hash, err := o.importMutation(mu); err != nil {
+			return nil, &MutationError{Index: i, Hash: hash, Err: err}
+		}
+	}
+	o.events = nil
+
+	return o, nil
+}
+
+func cloneElements(elements map[string]*KeyValue) map[string]*KeyValue {
+	clone := make(map[string]*KeyValue, len(elements))
+	for key, elem := range elements {
+		tags := make(map[Tag]*ValueDetail, len(elem.Tags))
+		for tag, value := range elem.Tags {
+			detail := *value
+			tags[tag] = &detail
+		}
+		clone[key] = &KeyValue{Tags: tags}
+	}
+
+	return clone
+}
+
+// MarshalSnapshot encodes a snapshot.
+func MarshalSnapshot(s *Snapshot) ([]byte, error) {
+	e := &encoder{}
+	e.buf.WriteByte(SnapshotVersion)
+
+	if len(s.Heads) > 0 {
+		e.uvarint(snapshotFieldHeads)
+		e.strings(s.Heads)
+	}
+	if len(s.Applied) > 0 {
+		e.uvarint(snapshotFieldApplied)
+		e.strings(s.Applied)
+	}
+
+	if version := nonZero(s.Version); len(version) > 0 {
+		e.uvarint(snapshotFieldVersion)
+		e.version(version)
+	}
+
+	if s.Sequence != 0 {
+		e.uvarint(snapshotFieldSequence)
+		e.uvarint(s.Sequence)
+	}
+
+	if len(s.Elements) > 0 {
+		keys := sortedKeys(s.Elements)
+		e.uvarint(snapshotFieldElements)
+		e.uvarint(uint64(len(keys)))
+		for _, key := range keys {
+			if err := e.element(key, s.Elements[key]); err != nil {
+				return nil, fmt.Errorf("failed to encode key %q: %w", key, err)
+			}
+		}
+	}
+
+	if checkpoint := nonZero(s.Checkpoint); len(checkpoint) > 0 {
+		e.uvarint(snapshotFieldCheckpoint)
+		e.version(checkpoint)
+	}
+
+	if len(s.Aliases) > 0 {
+		e.uvarint(snapshotFieldAliases)
+		e.strings(s.Aliases)
+	}
+
+	e.uvarint(0)
+
+	return e.buf.Bytes(), nil
+}
+
+func (e *encoder) strings(ss []string) {
+	e.uvarint(uint64(len(ss)))
+	for _, s := range ss {
+		e.string(s)
+	}
+}
+
+// Flags of the tags of a snapshot element.
+const (
+	elementTombstone = 1 << iota
+	elementHash
+	elementOwner
+	elementTime
+	elementTimestamp
+)
+
+// element encodes the key and the count of its tags, followed by every tag
+// with its flags, value, and the hash, owner, time and timestamp of the write
+// that added it if the flags say so.
+func (e *encoder) element(key string, kv *KeyValue) error {
+	e.string(key)
+
+	tags := make([]Tag, 0, len(kv.Tags))
+	for tag := range kv.Tags {
+		tags = append(tags, tag)
+	}
+	slices.SortFunc(tags, compareTags)
+	e.uvarint(uint64(len(tags)))
+	for _, tag := range tags {
+		detail := kv.Tags[tag]
+		e.tag(tag)
+		var flags byte
+		if detail.Tombstone {
+			flags |= elementTombstone
+		}
+		if detail.Hash != "" {
+			flags |= elementHash
+		}
+		if detail.Owner != "" {
+			flags |= elementOwner
+		}
+		if !detail.Time.IsZero() {
+			flags |= elementTime
+		}
+		if !detail.Timestamp.IsZero() {
+			flags |= elementTimestamp
+		}
+		e.buf.WriteByte(flags)
+		if err := e.value(detail.Value); err != nil {
+			return err
+		}
+		if detail.Hash != "" {
+			e.string(detail.Hash)
+		}
+		if detail.Owner != "" {
+			e.string(detail.Owner)
+		}
+		if !detail.Time.IsZero() {
+			e.time(detail.Time)
+		}
+		if !detail.Timestamp.IsZero() {
+			e.timestamp(detail.Timestamp)
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalSnapshot decodes a snapshot encoded by MarshalSnapshot.
+func UnmarshalSnapshot(b []byte) (*Snapshot, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: empty input", ErrInvalidEncoding)
+	}
+	if b[0] != SnapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported snapshot version %d", ErrInvalidEncoding, b[0])
+	}
+
+	d := &decoder{buf: b[1:]}
+	s := &Snapshot{
+		Version:    make(Version),
+		Elements:   make(map[string]*KeyValue),
+		Checkpoint: make(Version),
+	}
+	err := d.fields(func(field uint64) error {
+		var err error
+		switch field {
+		case snapshotFieldHeads:
+			s.Heads, err = d.strings()
+		case snapshotFieldApplied:
+			s.Applied, err = d.strings()
+		case snapshotFieldVersion:
+			s.Version, err = d.version()
+		case snapshotFieldSequence:
+			s.Sequence, err = d.uvarint()
+		case snapshotFieldElements:
+			var n int
+			if n, err = d.count(); err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				key, kv, err := d.element()
+				if err != nil {
+					return err
+				}
+				s.Elements[key] = kv
+			}
+		case snapshotFieldCheckpoint:
+			s.Checkpoint, err = d.version()
+		case snapshotFieldAliases:
+			s.Aliases, err = d.strings()
+		default:
+			return fmt.Errorf("%w: unknown snapshot field %d", ErrInvalidEncoding, field)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(d.buf) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, len(d.buf))
+	}
+
+	return s, nil
+}
+
+func (d *decoder) strings() ([]string, error) {
+	n, err := d.count()
+	if err != nil {
+		return nil, err
+	}
+	ss := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		s, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+func (d *decoder) nonEmptyString() (string, error) {
+	s, err := d.string()
+	if err != nil {
+		return "", err
+	}
+	if s == "" {
+		return "", fmt.Errorf("%w: empty string", ErrInvalidEncoding)
+	}
+
+	return s, nil
+}
+
+func (d *decoder) element() (string, *KeyValue, error) {
+	key, err := d.string()
+	if err != nil {
+		return "", nil, err
+	}
+	n, err := d.uvarint()
+	if err != nil {
+		return "", nil, err
+	}
+	if n > uint64(len(d.buf)) {
+		return "", nil, fmt.Errorf("%w: unexpected end of input", ErrInvalidEncoding)
+	}
+
+	kv := &KeyValue{Tags: make(map[Tag]*ValueDetail, n)}
+	for i := uint64(0); i < n; i++ {
+		tag, err := d.tag()
+		if err != nil {
+			return "", nil, err
+		}
+		flags, err := d.byte()
+		if err != nil {
+			return "", nil, err
+		}
+		if flags > elementTombstone|elementHash|elementOwner|elementTime|elementTimestamp {
+			return "", nil, fmt.Errorf("%w: element flags %d", ErrInvalidEncoding, flags)
+		}
+		value, err := d.value()
+		if err != nil {
+			return "", nil, err
+		}
+		detail := &ValueDetail{Value: value, Tombstone: flags&elementTombstone != 0}
+		if flags&elementHash != 0 {
+			if detail.Hash, err = d.nonEmptyString(); err != nil {
+				return "", nil, err
+			}
+		}
+		if flags&elementOwner != 0 {
+			if detail.Owner, err = d.nonEmptyString(); err != nil {
+				return "", nil, err
+			}
+		}
+		if flags&elementTime != 0 {
+			if detail.Time, err = d.time(); err != nil {
+				return "", nil, err
+			}
+		}
+		if flags&elementTimestamp != 0 {
+			if detail.Timestamp, err = d.timestamp(); err != nil {
+				return "", nil, err
+			}
+		}
+		kv.Tags[tag] = detail
+	}
+
+	return key, kv, nil
+}
@@ -0,0 +1,184 @@
+package crdt
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// editConcurrently makes a and b edit the same keys concurrently, exchanging
+// their logs every few edits.
+func editConcurrently(t *testing.T, a, b *ORSetMap, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		key := "key" + strconv.Itoa(i%7)
+		must(a.Add(key, scalar.New(int64(i))))
+		if i%3 == 0 {
+			must(b.Remove(key))
+		} else {
+			must(b.Add(key, scalar.New(strconv.Itoa(i))))
+		}
+		if i%5 == 0 {
+			require.NoError(t, b.ImportLog(must(a.ExportLog())))
+			require.NoError(t, a.ImportLog(must(b.ExportLog())))
+		}
+	}
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	editConcurrently(t, a, b, 40)
+	snapshot := a.Snapshot()
+	editConcurrently(t, a, b, 40)
+
+	log := must(a.ExportLog())
+	covered := make(map[string]bool)
+	for _, hash := range snapshot.Applied {
+		covered[hash] = true
+	}
+	var tail []Mutation
+	for _, mu := range log {
+		if !covered[must(HashMutation(mu))] {
+			tail = append(tail, mu)
+		}
+	}
+	require.NotEmpty(t, tail)
+
+	replayed := must(NewORSetMap(WithReplicaID("c")))
+	require.NoError(t, replayed.ImportLog(log))
+
+	encoded, err := MarshalSnapshot(snapshot)
+	require.NoError(t, err)
+	decoded, err := UnmarshalSnapshot(encoded)
+	require.NoError(t, err)
+	restored, err := Restore(decoded, tail, WithReplicaID("c"))
+	require.NoError(t, err)
+
+	assert.Equal(t, replayed.Snapshot().Elements, restored.Snapshot().Elements)
+	assert.Equal(t, replayed.List(), restored.List())
+	assert.Equal(t, replayed.Heads(), restored.Heads())
+	assert.Equal(t, replayed.Version(), restored.Version())
+	assert.Equal(t, replayed.State(), restored.State())
+	assert.Equal(t, replayed.Snapshot().Applied, restored.Snapshot().Applied)
+
+	// both continue with the same sequence and take the same covered mutations
+	must(replayed.Add("new", scalar.New("c")))
+	must(restored.Add("new", scalar.New("c")))
+	assert.Equal(t, replayed.Version(), restored.Version())
+	require.NoError(t, restored.ImportLog(log))
+	assert.Equal(t, replayed.List(), restored.List())
+
+	// only the tail can be served
+	assert.Len(t, must(restored.ExportLog()), len(tail)+1)
+}
+
+func TestRestoreWithStore(t *testing.T) {
+	s, err := OpenFileStore(filepath.Join(t.TempDir(), "log"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	a := must(NewORSetMap(WithReplicaID("a"), WithStore(s)))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	editConcurrently(t, a, b, 20)
+	snapshot := a.Snapshot()
+	editConcurrently(t, a, b, 20)
+
+	// the store holds the mutations after the snapshot as well
+	restored, err := Restore(snapshot, nil, WithReplicaID("a"), WithStore(s))
+	require.NoError(t, err)
+	assert.Equal(t, a.List(), restored.List())
+	assert.Equal(t, a.Heads(), restored.Heads())
+	assert.Equal(t, hashLog(must(a.ExportLog())), hashLog(must(restored.ExportLog())))
+}
+
+func TestSnapshotCheckpoint(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	editConcurrently(t, a, b, 20)
+	exchange(t, a, b)
+	require.NotEmpty(t, must(a.Checkpoint()))
+
+	// b writes without the checkpoint, naming folded mutations as parents
+	must(b.Add("lagging", scalar.New("b")))
+	lagging := must(b.ExportLog())
+
+	snapshot := a.Snapshot()
+	require.NotEmpty(t, snapshot.Checkpoint)
+	require.NotEmpty(t, snapshot.Aliases)
+	decoded := must(UnmarshalSnapshot(must(MarshalSnapshot(snapshot))))
+	assert.Equal(t, snapshot.Checkpoint, decoded.Checkpoint)
+	assert.Equal(t, snapshot.Aliases, decoded.Aliases)
+
+	restored := must(Restore(decoded, nil, WithReplicaID("c")))
+	require.NoError(t, a.ImportLog(lagging))
+	require.NoError(t, restored.ImportLog(lagging))
+	assert.Equal(t, Complete, restored.State())
+	assert.Equal(t, a.List(), restored.List())
+	assert.Equal(t, a.Heads(), restored.Heads())
+}
+
+func TestRestoreCheckpoint(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	editConcurrently(t, a, b, 20)
+	must(a.Add("removed", scalar.New("a")))
+	must(a.Add("removed later", scalar.New("a")))
+	must(a.Remove("removed"))
+	exchange(t, a, b)
+
+	restored := must(Restore(a.Snapshot(), nil, WithReplicaID("a")))
+	must(restored.Add("new", scalar.New("a")))
+	must(restored.Remove("removed later"))
+	require.NoError(t, b.ImportLog(must(restored.ExportLog())))
+	restored.Acknowledge(b.ReplicaID(), b.Version())
+	want := restored.List()
+
+	// the restored mutations count as folded and their values are carried
+	hash := must(restored.Checkpoint())
+	require.NotEmpty(t, hash)
+	assert.Equal(t, []string{hash}, restored.Heads())
+	assert.Equal(t, want, restored.List())
+	assert.NotContains(t, want, "removed")
+	assert.NotContains(t, want, "removed later")
+
+	require.NoError(t, b.ImportLog(must(restored.ExportLog())))
+	assert.Equal(t, restored.Heads(), b.Heads())
+	assert.Equal(t, want, b.List())
+}
+
+func TestSnapshotEncoding(t *testing.T) {
+	empty := must(NewORSetMap()).Snapshot()
+	encoded, err := MarshalSnapshot(empty)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{SnapshotVersion, 0}, encoded)
+	decoded, err := UnmarshalSnapshot(encoded)
+	require.NoError(t, err)
+	restored, err := Restore(decoded, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Empty, restored.State())
+
+	invalid := map[string][]byte{
+		"Empty input":     {},
+		"Unknown version": {2, 0},
+		"Unknown field":   {SnapshotVersion, 9, 0},
+		"Empty heads":     {SnapshotVersion, snapshotFieldHeads, 0, 0},
+		"Unknown flag":    {SnapshotVersion, snapshotFieldElements, 1, 1, 'k', 1, 1, 'a', 1, 32, 0, 1, 'v', 0},
+		"Empty hash":      {SnapshotVersion, snapshotFieldElements, 1, 1, 'k', 1, 1, 'a', 1, elementHash, 0, 1, 'v', 0, 0},
+		"Trailing bytes":  {SnapshotVersion, 0, 0},
+		"Truncated":       {SnapshotVersion, snapshotFieldSequence},
+	}
+	for name, b := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := UnmarshalSnapshot(b)
+			assert.ErrorIs(t, err, ErrInvalidEncoding)
+		})
+	}
+}
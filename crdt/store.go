@@ -0,0 +1,194 @@
+package crdt
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by a Store for a hash it does not hold.
+	ErrNotFound = errors.New("mutation not found")
+	// ErrCorruptStore is returned when a store holds data that cannot be
+	// read back and was not left behind by an interrupted write.
+	ErrCorruptStore = errors.New("corrupt store")
+)
+
+// Store persists the mutations of an ORSetMap log, keyed by their hash. An
+// ORSetMap puts every mutation it accepts, including those still waiting for
+// their parents, and reads them back when it is created. Implementations
+// must be safe for concurrent use.
+type Store interface {
+	// Put stores the mutation under the given hash. Putting a hash that is
+	// already stored does nothing.
+	Put(hash string, mu Mutation) error
+	// Get returns the mutation stored under the given hash, or ErrNotFound.
+	Get(hash string) (Mutation, error)
+	// Heads returns the hashes of the stored mutations that are not the
+	// parent of another stored mutation, in sorted order.
+	Heads() ([]string, error)
+	// Iterate calls fn for every stored mutation in causal order, parents
+	// before children, and stops at the first error fn returns.
+	Iterate(fn func(hash string, mu Mutation) error) error
+	// Delete removes the mutations stored under the given hashes. Hashes
+	// that are not stored are ignored.
+	Delete(hashes []string) error
+}
+
+// MemoryStore is a Store that keeps the mutations in memory.
+type MemoryStore struct {
+	mutations map[string]Mutation
+	order     []string // hashes in the order they were put
+	heads     storeHeads
+	mu        sync.Mutex
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mutations: make(map[string]Mutation),
+		heads:     newStoreHeads(),
+	}
+}
+
+func (s *MemoryStore) Put(hash string, mu Mutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.mutations[hash]; exists {
+		return nil
+	}
+	s.mutations[hash] = mu
+	s.order = append(s.order, hash)
+	s.heads.add(hash, mu.Parents)
+
+	return nil
+}
+
+func (s *MemoryStore) Get(hash string) (Mutation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mu, exists := s.mutations[hash]
+	if !exists {
+		return Mutation{}, ErrNotFound
+	}
+
+	return mu, nil
+}
+
+func (s *MemoryStore) Heads() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.heads.list(), nil
+}
+
+func (s *MemoryStore) Iterate(fn func(hash string, mu Mutation) error) error {
+	s.mu.Lock()
+	order := slices.Clone(s.order)
+	mutations := make(map[string]Mutation, len(order))
+	for _, hash := range order {
+		mutations[hash] = s.mutations[hash]
+	}
+	s.mu.Unlock()
+
+	return iterateCausal(order, mutations, fn)
+}
+
+func (s *MemoryStore) Delete(hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, hash := range hashes {
+		delete(s.mutations, hash)
+	}
+	s.order = slices.DeleteFunc(s.order, func(hash string) bool {
+		_, exists := s.mutations[hash]
+		return !exists
+	})
+	s.heads = newStoreHeads()
+	for _, hash := range s.order {
+		s.heads.add(hash, s.mutations[hash].Parents)
+	}
+
+	return nil
+}
+
+// storeHeads tracks the heads of the mutations in a store.
+type storeHeads struct {
+	heads  map[string]struct{}
+	parent map[string]struct{} // hashes that are the parent of a stored mutation
+}
+
+func newStoreHeads() storeHeads {
+	return storeHeads{
+		heads:  make(map[string]struct{}),
+		parent: make(map[string]struct{}),
+	}
+}
+
+func (h storeHeads) add(hash string, parents []string) {
+	for _, parent := range parents {
+		h.parent[parent] = struct{}{}
+		delete(h.heads, parent)
+	}
+	if _, exists := h.parent[hash]; !exists {
+		h.heads[hash] = struct{}{}
+	}
+}
+
+func (h storeHeads) list() []string {
+	heads := make([]string, 0, len(h.heads))
+	for hash := range h.heads {
+		heads = append(heads, hash)
+	}
+	slices.Sort(heads)
+
+	return heads
+}
+
+// iterateCausal calls fn for the mutations in the given order, except that a
+// mutation is held back until its parents that are among the mutations have
+// been visited.
+func iterateCausal(order []string, mutations map[string]Mutation, fn func(hash string, mu Mutation) error) error {
+	visited := make(map[string]bool, len(order))
+	missing := make(map[string]int)
+	waiting := make(map[string][]string)
+
+	for _, hash := range order {
+		if visited[hash] {
+			continue
+		}
+		for _, parent := range mutations[hash].Parents {
+			if _, stored := mutations[parent]; stored && !visited[parent] {
+				waiting[parent] = append(waiting[parent], hash)
+				missing[hash]++
+			}
+		}
+		if missing[hash] > 0 {
+			continue
+		}
+
+		queue := []string{hash}
+		for len(queue) > 0 {
+			hash := queue[0]
+			queue = queue[1:]
+
+			visited[hash] = true
+			if err := fn(hash, mutations[hash]); err != nil {
+				return err
+			}
+			for _, child := range waiting[hash] {
+				missing[child]--
+				if missing[child] == 0 {
+					delete(missing, child)
+					queue = append(queue, child)
+				}
+			}
+			delete(waiting, hash)
+		}
+	}
+
+	return nil
+}
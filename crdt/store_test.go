@@ -0,0 +1,121 @@
+package crdt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// storeFactories create empty stores of every implementation.
+var storeFactories = []struct {
+	name string
+	new  func(t *testing.T) Store
+}{
+	{
+		name: "Memory",
+		new:  func(t *testing.T) Store { return NewMemoryStore() },
+	},
+	{
+		name: "File",
+		new: func(t *testing.T) Store {
+			s, err := OpenFileStore(filepath.Join(t.TempDir(), "log"))
+			require.NoError(t, err)
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	},
+}
+
+func TestStore(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	must(a.Add("title", scalar.New("draft")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	must(a.Add("title", scalar.New("a")))
+	must(b.Add("body", scalar.New("b")))
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	log := must(a.ExportLog())
+	require.Len(t, log, 3)
+	hashes := hashLog(log)
+
+	for _, factory := range storeFactories {
+		t.Run(factory.name, func(t *testing.T) {
+			s := factory.new(t)
+			assert.Empty(t, must(s.Heads()))
+			_, err := s.Get(hashes[0])
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// children are put before their parents
+			for i := len(log) - 1; i >= 0; i-- {
+				require.NoError(t, s.Put(hashes[i], log[i]))
+			}
+			require.NoError(t, s.Put(hashes[2], log[2]))
+
+			mu, err := s.Get(hashes[1])
+			require.NoError(t, err)
+			assert.Equal(t, hashes[1], must(HashMutation(mu)))
+			assert.ElementsMatch(t, hashes[1:], must(s.Heads()))
+
+			var visited []string
+			require.NoError(t, s.Iterate(func(hash string, mu Mutation) error {
+				visited = append(visited, hash)
+				return nil
+			}))
+			require.Len(t, visited, 3)
+			assert.Equal(t, hashes[0], visited[0])
+			assert.ElementsMatch(t, hashes[1:], visited[1:])
+
+			require.NoError(t, s.Delete([]string{hashes[0], hashes[1], "unknown"}))
+			_, err = s.Get(hashes[0])
+			assert.ErrorIs(t, err, ErrNotFound)
+			mu, err = s.Get(hashes[2])
+			require.NoError(t, err)
+			assert.Equal(t, hashes[2], must(HashMutation(mu)))
+			assert.Equal(t, hashes[2:], must(s.Heads()))
+		})
+	}
+}
+
+func TestORSetMapStore(t *testing.T) {
+	for _, factory := range storeFactories {
+		t.Run(factory.name, func(t *testing.T) {
+			s := factory.new(t)
+			a := must(NewORSetMap(WithReplicaID("a"), WithStore(s)))
+			must(a.Add("title", scalar.New("draft")))
+			must(a.Add("body", scalar.New("text")))
+			must(a.Remove("title"))
+			assert.Equal(t, a.Heads(), must(s.Heads()))
+
+			// a parked mutation is stored as well
+			other := must(NewORSetMap(WithReplicaID("b")))
+			must(other.Add("x", scalar.New("x")))
+			must(other.Add("y", scalar.New("y")))
+			require.ErrorIs(t, a.ImportLog(must(other.ExportLog())[1:]), ErrUnknownParent)
+
+			reopened := must(NewORSetMap(WithReplicaID("a"), WithStore(s)))
+			assert.Equal(t, a.List(), reopened.List())
+			assert.Equal(t, a.Heads(), reopened.Heads())
+			assert.Equal(t, a.Version(), reopened.Version())
+			assert.Equal(t, a.PendingMutations(), reopened.PendingMutations())
+			assert.Equal(t, hashLog(must(a.ExportLog())), hashLog(must(reopened.ExportLog())))
+
+			// the reopened map continues the sequence of the replica
+			must(reopened.Add("title", scalar.New("final")))
+			assert.Equal(t, Version{"a": 4}, reopened.Version())
+		})
+	}
+}
+
+// hashLog returns the hashes of the mutations.
+func hashLog(log []Mutation) []string {
+	hashes := make([]string, len(log))
+	for i, mu := range log {
+		hashes[i] = must(HashMutation(mu))
+	}
+
+	return hashes
+}
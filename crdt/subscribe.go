@@ -0,0 +1,175 @@
+package crdt
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Change describes how an applied mutation changed the value of a key.
+type Change struct {
+	Key   string
+	Old   Value  // nil if the key had no value
+	New   Value  // nil if the key was removed
+	Hash  string // hash of the mutation
+	Local bool   // the mutation was created by this replica
+}
+
+// Filter selects the keys a subscriber is notified about.
+type Filter func(key string) bool
+
+// KeyPrefix returns a filter that selects the keys with the given prefix.
+func KeyPrefix(prefix string) Filter {
+	return func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}
+}
+
+// subscription queues the changes for a subscriber, so that a slow subscriber
+// neither blocks the map nor misses changes.
+type subscription struct {
+	filter Filter
+	ch     chan Change
+	queue  []Change
+	ready  chan struct{} // signaled when the queue grows
+	done   chan struct{} // closed on cancel
+	mu     sync.Mutex
+}
+
+// Subscribe returns a channel that receives a Change for every applied
+// mutation that changes the resolved value of a key the filter selects, or of
+// any key if the filter is nil. Local and imported mutations are reported in
+// the causal order they were applied in. Changes are queued for the
+// subscriber and the filter runs on the subscriber's side, so the map is
+// never blocked by either. cancel stops the subscription and closes the
+// channel; queued changes are dropped.
+func (o *ORSetMap) Subscribe(filter Filter) (<-chan Change, func()) {
+	s := &subscription{
+		filter: filter,
+		ch:     make(chan Change),
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	o.mu.Lock()
+	o.subscriptions[s] = struct{}{}
+	o.mu.Unlock()
+
+	go s.run()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			o.mu.Lock()
+			delete(o.subscriptions, s)
+			o.mu.Unlock()
+			close(s.done)
+		})
+	}
+
+	return s.ch, cancel
+}
+
+// run delivers the queued changes until the subscription is cancelled.
+func (s *subscription) run() {
+	defer close(s.ch)
+
+	for {
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, change := range queue {
+			if s.filter != nil && !s.filter(change.Key) {
+				continue
+			}
+			select {
+			case s.ch <- change:
+			case <-s.done:
+				return
+			}
+		}
+
+		select {
+		case <-s.ready:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *subscription) push(change Change) {
+	s.mu.Lock()
+	s.queue = append(s.queue, change)
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// resolved is the resolved value of a key and the live tags it was resolved
+// from.
+type resolved struct {
+	tags  []Tag
+	value Value
+}
+
+// winners returns the resolved values of the keys the mutation may change.
+func (o *ORSetMap) winners(mu Mutation) map[string]resolved {
+	winners := make(map[string]resolved)
+	resolve := func(key string) {
+		winners[key] = o.resolved(key)
+	}
+
+	for _, op := range mu.Operations {
+		resolve(op.Key)
+	}
+	if len(mu.Checkpoint) > 0 {
+		// a checkpoint drops tags of any key
+		for key := range o.elements {
+			resolve(key)
+		}
+	}
+
+	return winners
+}
+
+func (o *ORSetMap) resolved(key string) resolved {
+	elem, exists := o.elements[key]
+	if !exists {
+		return resolved{}
+	}
+	tags := make([]Tag, 0, len(elem.liveTags()))
+	for tag := range elem.liveTags() {
+		tags = append(tags, tag)
+	}
+	slices.SortFunc(tags, compareTags)
+
+	return resolved{tags: tags, value: o.resolve(key, elem)}
+}
+
+// publish queues a Change for every key whose resolved value the mutation
+// changed.
+func (o *ORSetMap) publish(hash string, mu Mutation, before map[string]resolved) {
+	local := mu.Origin.ReplicaID == o.replicaID
+	for _, key := range sortedKeys(before) {
+		old, after := before[key], o.resolved(key)
+		if slices.Equal(old.tags, after.tags) || equalValues(old.value, after.value) {
+			continue
+		}
+
+		change := Change{
+			Key:   key,
+			Old:   old.value,
+			New:   after.value,
+			Hash:  hash,
+			Local: local,
+		}
+		for s := range o.subscriptions {
+			s.push(change)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package crdt
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// receive reads n changes from the channel.
+func receive(t *testing.T, ch <-chan Change, n int) []Change {
+	t.Helper()
+
+	changes := make([]Change, 0, n)
+	for len(changes) < n {
+		select {
+		case change, ok := <-ch:
+			require.True(t, ok, "channel closed after %d changes", len(changes))
+			changes = append(changes, change)
+		case <-time.After(time.Second):
+			require.FailNow(t, "timed out", "received %d of %d changes", len(changes), n)
+		}
+	}
+
+	return changes
+}
+
+func TestSubscribe(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	ch, cancel := b.Subscribe(nil)
+	defer cancel()
+
+	remote := must(a.Add("title", scalar.New("a")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	local := must(b.Add("title", scalar.New("b")))
+	removed := must(b.Remove("title"))
+	// removing an absent key changes nothing
+	must(b.Remove("title"))
+
+	assert.Equal(t, []Change{
+		{Key: "title", New: scalar.New("a"), Hash: remote},
+		{Key: "title", Old: scalar.New("a"), New: scalar.New("b"), Hash: local, Local: true},
+		{Key: "title", Old: scalar.New("b"), Hash: removed, Local: true},
+	}, receive(t, ch, 3))
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	o := must(NewORSetMap())
+	ch, cancel := o.Subscribe(KeyPrefix("post/"))
+	defer cancel()
+
+	must(o.Add("draft", scalar.New(true)))
+	hash := must(o.Batch(func(tx *Tx) error {
+		tx.Add("post/title", scalar.New("title"))
+		tx.Add("post/body", scalar.New("body"))
+		tx.Add("user", scalar.New("user"))
+		return nil
+	}))
+
+	// keys of a mutation are reported in sorted order
+	assert.Equal(t, []Change{
+		{Key: "post/body", New: scalar.New("body"), Hash: hash, Local: true},
+		{Key: "post/title", New: scalar.New("title"), Hash: hash, Local: true},
+	}, receive(t, ch, 2))
+}
+
+func TestSubscribeCausalOrder(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	for i := 0; i < 100; i++ {
+		must(a.Add("key", scalar.New(int64(i))))
+	}
+	log := must(a.ExportLog())
+	slices.Reverse(log)
+
+	b := must(NewORSetMap(WithReplicaID("b")))
+	ch, cancel := b.Subscribe(nil)
+	defer cancel()
+	require.NoError(t, b.ImportLog(log))
+
+	for i, change := range receive(t, ch, 100) {
+		assert.Equal(t, scalar.New(int64(i)), change.New)
+		assert.False(t, change.Local)
+	}
+}
+
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	o := must(NewORSetMap())
+	slow, cancel := o.Subscribe(nil)
+	defer cancel()
+	fast, cancelFast := o.Subscribe(nil)
+	defer cancelFast()
+
+	// nobody reads slow while the map is written
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1_000; i++ {
+			must(o.Add(fmt.Sprintf("key%d", i), scalar.New(int64(i))))
+		}
+	}()
+	receive(t, fast, 1_000)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.FailNow(t, "writes blocked by a slow subscriber")
+	}
+
+	for i, change := range receive(t, slow, 1_000) {
+		assert.Equal(t, fmt.Sprintf("key%d", i), change.Key)
+	}
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	o := must(NewORSetMap())
+	ch, cancel := o.Subscribe(nil)
+	kept, cancelKept := o.Subscribe(nil)
+	defer cancelKept()
+
+	must(o.Add("key", scalar.New("a")))
+	cancel()
+	cancel()
+	must(o.Add("key", scalar.New("b")))
+
+	select {
+	case _, ok := <-ch:
+		// a change queued before cancel may be delivered first
+		if ok {
+			_, ok = <-ch
+		}
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		require.FailNow(t, "channel not closed")
+	}
+	assert.Len(t, receive(t, kept, 2), 2)
+	assert.Len(t, o.subscriptions, 1)
+}
+
+func TestSubscribeEqualValue(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("a")))
+	must(o.Add("price", scalar.New(scalar.NewDecimal(10, 1))))
+	ch, cancel := o.Subscribe(nil)
+	defer cancel()
+
+	// rewriting an equal value changes nothing
+	must(o.Add("price", scalar.New(scalar.NewDecimal(100, 2))))
+	changed := must(o.Add("price", scalar.New(scalar.NewDecimal(2, 0))))
+	assert.Equal(t, []Change{{
+		Key:   "price",
+		Old:   scalar.New(scalar.NewDecimal(100, 2)),
+		New:   scalar.New(scalar.NewDecimal(2, 0)),
+		Hash:  changed,
+		Local: true,
+	}}, receive(t, ch, 1))
+}
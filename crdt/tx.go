@@ -0,0 +1,81 @@
+package crdt
+
+// Tx collects the operations of a batch. Its methods only record the
+// operations; they take effect together when the batch is committed.
+type Tx struct {
+	keys    []string // keys in the order they were first written
+	changes map[string]*txChange
+}
+
+// txChange is the net effect of a batch on a key.
+type txChange struct {
+	remove bool // the observed tags of the key are removed
+	add    bool // value is set after the removal
+	value  Value
+}
+
+// Add sets the value of key.
+func (tx *Tx) Add(key string, value Value) {
+	change := tx.change(key)
+	change.add = true
+	change.value = value
+}
+
+// Remove removes key, including a value set earlier in the same batch.
+func (tx *Tx) Remove(key string) {
+	change := tx.change(key)
+	change.remove = true
+	change.add = false
+	change.value = nil
+}
+
+func (tx *Tx) change(key string) *txChange {
+	if tx.changes == nil {
+		tx.changes = make(map[string]*txChange)
+	}
+	change, exists := tx.changes[key]
+	if !exists {
+		change = &txChange{}
+		tx.changes[key] = change
+		tx.keys = append(tx.keys, key)
+	}
+
+	return change
+}
+
+// Batch calls fn to collect operations and commits them as a single mutation,
+// which is applied atomically, and returns its hash. Nothing is applied if fn
+// returns an error, which Batch returns unchanged, or if the mutation is
+// rejected. A batch without operations commits nothing and returns an empty
+// hash. fn is called without the map being locked.
+func (o *ORSetMap) Batch(fn func(tx *Tx) error) (string, error) {
+	tx := &Tx{}
+	if err := fn(tx); err != nil {
+		return "", err
+	}
+	if len(tx.keys) == 0 {
+		return "", nil
+	}
+
+	o.mu.Lock()
+	defer o.unlock()
+
+	origin := o.nextOrigin()
+	ops := make([]*Operation, 0, len(tx.keys))
+	for _, key := range tx.keys {
+		change := tx.changes[key]
+		if change.remove {
+			ops = append(ops, o.removeOperation(key))
+		}
+		if change.add {
+			ops = append(ops, o.addOperation(key, change.value, origin))
+		}
+	}
+	mu := Mutation{
+		Operations: ops,
+		Parents:    o.getLeaves(),
+		Origin:     origin,
+	}
+
+	return o.commit(mu)
+}
@@ -0,0 +1,100 @@
+package crdt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestBatch(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	must(a.Add("draft", scalar.New(true)))
+	must(a.Add("tag", scalar.New("go")))
+
+	hash, err := a.Batch(func(tx *Tx) error {
+		tx.Add("title", scalar.New("title"))
+		tx.Add("body", scalar.New("first"))
+		tx.Add("body", scalar.New("body"))
+		tx.Remove("draft")
+		tx.Add("tmp", scalar.New("tmp"))
+		tx.Remove("tmp")
+		tx.Remove("tag")
+		tx.Add("tag", scalar.New("crdt"))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{hash}, a.Heads())
+
+	mu, ok := a.Mutation(hash)
+	require.True(t, ok)
+	assert.Len(t, mu.Operations, 6)
+	assert.Equal(t, Tag{ReplicaID: "a", Sequence: 3}, mu.Origin)
+	want := map[string]Value{
+		"title": scalar.New("title"),
+		"body":  scalar.New("body"),
+		"tag":   scalar.New("crdt"),
+	}
+	assert.Equal(t, want, a.List())
+
+	b := must(NewORSetMap(WithReplicaID("b")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	assert.Equal(t, want, b.List())
+	assert.Equal(t, a.Heads(), b.Heads())
+}
+
+func TestBatchRollback(t *testing.T) {
+	errAbort := errors.New("abort")
+	tests := []struct {
+		name string
+		fn   func(tx *Tx) error
+		err  error
+	}{
+		{
+			name: "Callback error",
+			fn: func(tx *Tx) error {
+				tx.Add("title", scalar.New("title"))
+				return errAbort
+			},
+			err: errAbort,
+		},
+		{
+			name: "Invalid operation",
+			fn: func(tx *Tx) error {
+				tx.Add("title", scalar.New("title"))
+				tx.Add("body", nil)
+				return nil
+			},
+			err: ErrInvalidOperation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			o := must(NewORSetMap(WithReplicaID("a")))
+			first := must(o.Add("title", scalar.New("first")))
+
+			hash, err := o.Batch(tc.fn)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Empty(t, hash)
+			assert.Equal(t, map[string]Value{"title": scalar.New("first")}, o.List())
+			assert.Equal(t, []string{first}, o.Heads())
+
+			// the sequence is not used up
+			second := must(o.Add("title", scalar.New("second")))
+			mu, _ := o.Mutation(second)
+			assert.Equal(t, uint64(2), mu.Origin.Sequence)
+		})
+	}
+}
+
+func TestBatchEmpty(t *testing.T) {
+	o := must(NewORSetMap())
+	hash, err := o.Batch(func(tx *Tx) error { return nil })
+	require.NoError(t, err)
+	assert.Empty(t, hash)
+	assert.Equal(t, Empty, o.State())
+}
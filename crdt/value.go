@@ -0,0 +1,74 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+var _ Value = scalar.Any{}
+
+// ToAny returns a value as a scalar.Any, whatever its implementation. It
+// returns an error if the value is nil or its accessors disagree with its
+// type.
+func ToAny(v Value) (scalar.Any, error) {
+	if v == nil {
+		return scalar.Any{}, errors.New("no value")
+	}
+
+	var (
+		a  scalar.Any
+		ok bool
+	)
+	switch v.Type() {
+	case scalar.String:
+		var s string
+		s, ok = v.String()
+		a = scalar.NewAny(s)
+	case scalar.Int64:
+		var i int64
+		i, ok = v.Int64()
+		a = scalar.NewAny(i)
+	case scalar.Uint64:
+		var u uint64
+		u, ok = v.Uint64()
+		a = scalar.NewAny(u)
+	case scalar.Float64:
+		var f float64
+		f, ok = v.Float64()
+		a = scalar.NewAny(f)
+	case scalar.ByteSlice:
+		var b []byte
+		b, ok = v.ByteSlice()
+		a = scalar.NewAny(b)
+	case scalar.Bool:
+		var b bool
+		b, ok = v.Bool()
+		a = scalar.NewAny(b)
+	case scalar.Timestamp:
+		var t time.Time
+		t, ok = v.Timestamp()
+		a = scalar.NewAny(t)
+	case scalar.Decimal:
+		var d scalar.DecimalValue
+		d, ok = v.Decimal()
+		a = scalar.NewAny(d)
+	case scalar.UUID:
+		var u scalar.UUIDValue
+		u, ok = v.UUID()
+		a = scalar.NewAny(u)
+	case scalar.Null:
+		var n scalar.NullValue
+		n, ok = v.Null()
+		a = scalar.NewAny(n)
+	default:
+		return scalar.Any{}, fmt.Errorf("unsupported value type %d", v.Type())
+	}
+	if !ok {
+		return scalar.Any{}, fmt.Errorf("%w: accessor of value type %d found no value", scalar.ErrTypeMismatch, v.Type())
+	}
+
+	return a, nil
+}
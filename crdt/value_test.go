@@ -0,0 +1,65 @@
+package crdt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// mismatched is a value whose accessors disagree with its type.
+type mismatched struct{ *scalar.Scalar[int64] }
+
+func (mismatched) Type() scalar.Type { return scalar.String }
+
+func TestToAny(t *testing.T) {
+	tests := []struct {
+		name  string
+		value Value
+		want  scalar.Any
+	}{
+		{name: "String", value: scalar.New("hello"), want: scalar.NewAny("hello")},
+		{name: "Int64", value: scalar.New(int64(-1)), want: scalar.NewAny(int64(-1))},
+		{name: "Uint64", value: scalar.New(uint64(1)), want: scalar.NewAny(uint64(1))},
+		{name: "Float64", value: scalar.New(1.5), want: scalar.NewAny(1.5)},
+		{name: "Byte slice", value: scalar.New([]byte("hello")), want: scalar.NewAny([]byte("hello"))},
+		{name: "Bool", value: scalar.New(true), want: scalar.NewAny(true)},
+		{name: "Timestamp", value: scalar.New(time.Unix(1, 0)), want: scalar.NewAny(time.Unix(1, 0))},
+		{name: "Decimal", value: scalar.New(scalar.NewDecimal(150, 2)), want: scalar.NewAny(scalar.NewDecimal(15, 1))},
+		{name: "UUID", value: scalar.New(scalar.UUIDValue{1}), want: scalar.NewAny(scalar.UUIDValue{1})},
+		{name: "Null", value: scalar.New(scalar.NullValue{}), want: scalar.NewAny(scalar.NullValue{})},
+		{name: "Any", value: scalar.NewAny("hello"), want: scalar.NewAny("hello")},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ToAny(tc.value)
+			require.NoError(t, err)
+			assert.True(t, tc.want.Equal(got))
+		})
+	}
+
+	_, err := ToAny(nil)
+	assert.Error(t, err)
+	_, err = ToAny(scalar.Any{})
+	assert.Error(t, err)
+	_, err = ToAny(mismatched{scalar.New(int64(1))})
+	assert.ErrorIs(t, err, scalar.ErrTypeMismatch)
+}
+
+func TestAnyValue(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	must(a.Add("count", scalar.NewAny(int64(3))))
+
+	b := must(NewORSetMap(WithReplicaID("b")))
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	got := must(ToAny(b.Get("count")))
+	v, err := scalar.As[int64](got)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), v)
+	_, err = scalar.As[uint64](got)
+	assert.ErrorIs(t, err, scalar.ErrTypeMismatch)
+}
@@ -0,0 +1,68 @@
+package crdt
+
+// Version is a version vector mapping replica IDs to the greatest origin
+// sequence of the mutations applied from that replica. Since every mutation
+// of a replica causally follows its previous ones, a version covers all
+// mutations of a replica up to that sequence.
+type Version map[string]uint64
+
+// Ordering is the causal relation between two versions.
+type Ordering int
+
+const (
+	Equal Ordering = iota
+	Before
+	After
+	Concurrent
+)
+
+// String returns the name of the ordering.
+func (o Ordering) String() string {
+	switch o {
+	case Equal:
+		return "equal"
+	case Before:
+		return "before"
+	case After:
+		return "after"
+	case Concurrent:
+		return "concurrent"
+	default:
+		return "unknown"
+	}
+}
+
+// Contains reports whether the mutation created with the given origin is
+// covered by the version.
+func (v Version) Contains(origin Tag) bool {
+	return origin.Sequence <= v[origin.ReplicaID]
+}
+
+// Compare returns whether version a is equal to, before, after or concurrent
+// with version b.
+func Compare(a, b Version) Ordering {
+	var aAhead, bAhead bool
+	for replica, seq := range a {
+		if seq > b[replica] {
+			aAhead = true
+			break
+		}
+	}
+	for replica, seq := range b {
+		if seq > a[replica] {
+			bAhead = true
+			break
+		}
+	}
+
+	switch {
+	case aAhead && bAhead:
+		return Concurrent
+	case aAhead:
+		return After
+	case bAhead:
+		return Before
+	default:
+		return Equal
+	}
+}
@@ -0,0 +1,80 @@
+package crdt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     Version
+		expected Ordering
+	}{
+		{name: "Both empty", a: Version{}, b: nil, expected: Equal},
+		{name: "Equal", a: Version{"a": 1, "b": 2}, b: Version{"a": 1, "b": 2}, expected: Equal},
+		{name: "Zero entries are absent", a: Version{"a": 1, "b": 0}, b: Version{"a": 1}, expected: Equal},
+		{name: "Before", a: Version{"a": 1}, b: Version{"a": 2}, expected: Before},
+		{name: "Before with missing replica", a: Version{"a": 1}, b: Version{"a": 1, "b": 1}, expected: Before},
+		{name: "After", a: Version{"a": 3, "b": 1}, b: Version{"a": 2}, expected: After},
+		{name: "Concurrent", a: Version{"a": 2}, b: Version{"b": 1}, expected: Concurrent},
+		{name: "Concurrent on shared replicas", a: Version{"a": 2, "b": 1}, b: Version{"a": 1, "b": 2}, expected: Concurrent},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Compare(tc.a, tc.b))
+		})
+	}
+}
+
+func TestVersionContains(t *testing.T) {
+	v := Version{"a": 3}
+	assert.True(t, v.Contains(Tag{ReplicaID: "a", Sequence: 3}))
+	assert.False(t, v.Contains(Tag{ReplicaID: "a", Sequence: 4}))
+	assert.False(t, v.Contains(Tag{ReplicaID: "b", Sequence: 1}))
+}
+
+func TestORSetMapHeadsAndVersion(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	assert.Empty(t, a.Heads())
+	assert.Equal(t, Equal, Compare(a.Version(), b.Version()))
+
+	a.Add("title", scalar.New("draft"))
+	a.Remove("title")
+	assert.Equal(t, Version{"a": 2}, a.Version())
+	log, err := a.ExportLog()
+	require.NoError(t, err)
+	require.Len(t, a.Heads(), 1)
+	assert.Equal(t, must(HashMutation(log[1])), a.Heads()[0])
+	assert.Equal(t, After, Compare(a.Version(), b.Version()))
+
+	require.NoError(t, b.ImportLog(log))
+	assert.Equal(t, a.Heads(), b.Heads())
+	assert.Equal(t, Equal, Compare(a.Version(), b.Version()))
+
+	// concurrent edits leave two heads once merged
+	a.Add("title", scalar.New("a"))
+	b.Add("body", scalar.New("b"))
+	assert.Equal(t, Version{"a": 3}, a.Version())
+	assert.Equal(t, Version{"a": 2, "b": 3}, b.Version())
+	assert.Equal(t, Concurrent, Compare(a.Version(), b.Version()))
+
+	require.NoError(t, b.ImportLog(must(a.ExportLog())))
+	assert.Len(t, b.Heads(), 2)
+	assert.Equal(t, After, Compare(b.Version(), a.Version()))
+
+	// a local mutation merges the heads
+	b.Add("tags", scalar.New("news"))
+	assert.Len(t, b.Heads(), 1)
+	assert.Equal(t, Version{"a": 3, "b": 4}, b.Version())
+
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	assert.Equal(t, b.Heads(), a.Heads())
+	assert.Equal(t, Equal, Compare(a.Version(), b.Version()))
+}
@@ -0,0 +1,140 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// View is a read-only state of a map, as of the mutations with the given
+// heads. It does not change as the map does.
+type View struct {
+	heads    []string
+	elements map[string]*KeyValue
+	resolve  func(key string, elem *KeyValue) Value
+}
+
+// At returns the state of the map as it was once the mutations with the given
+// hashes were applied, computed from those mutations and their ancestors
+// only. Without heads the view is empty. It fails with ErrUnknownMutation if
+// a head is not an applied mutation of the log, and with ErrFolded if a head
+// or one of its ancestors was folded into a checkpoint that is not an
+// ancestor itself. The history a map was restored from a snapshot with cannot
+// be viewed unless its store holds the mutations, so At fails with ErrFolded
+// for the mutations the snapshot covers as well.
+func (o *ORSetMap) At(heads ...string) (View, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	heads = slices.Clone(heads)
+	slices.Sort(heads)
+	heads = slices.Compact(heads)
+	for _, hash := range heads {
+		if !o.mutations[hash] {
+			return View{}, fmt.Errorf("%w: %s", ErrUnknownMutation, hash)
+		}
+	}
+
+	// a checkpoint stands in for the mutations it folds, so the walk stops at
+	// checkpoints; a folded ancestor must be one of their parents
+	ancestors := make(map[string]Mutation)
+	visited := make(map[string]bool)
+	covered := make(map[string]bool)
+	var folded []string
+	stack := slices.Clone(heads)
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[hash] {
+			continue
+		}
+		visited[hash] = true
+		if _, alias := o.aliases[hash]; alias {
+			folded = append(folded, hash)
+			continue
+		}
+		mu, err := o.store.Get(hash)
+		if errors.Is(err, ErrNotFound) {
+			// only the snapshot the map was restored from holds its state
+			return View{}, fmt.Errorf("%w: %s", ErrFolded, hash)
+		}
+		if err != nil {
+			return View{}, fmt.Errorf("failed to retrieve mutation with hash %s: %w", hash, err)
+		}
+		ancestors[hash] = mu
+		if len(mu.Checkpoint) > 0 {
+			for _, parent := range mu.Parents {
+				covered[parent] = true
+			}
+			continue
+		}
+		stack = append(stack, mu.Parents...)
+	}
+	for _, hash := range folded {
+		if !covered[hash] {
+			return View{}, fmt.Errorf("%w: %s", ErrFolded, hash)
+		}
+	}
+
+	elements := make(map[string]*KeyValue)
+	version := make(Version)
+	for _, hash := range o.order {
+		mu, exists := ancestors[hash]
+		if !exists {
+			continue
+		}
+		if len(mu.Checkpoint) > 0 {
+			applyCheckpoint(elements, hash, mu, version)
+			for replica, seq := range mu.Checkpoint {
+				version[replica] = max(version[replica], seq)
+			}
+		} else {
+			applyOperations(elements, hash, mu)
+		}
+		if origin := mu.Origin; origin.ReplicaID != "" {
+			version[origin.ReplicaID] = max(version[origin.ReplicaID], origin.Sequence)
+		}
+	}
+
+	// readers of the view share its elements, so the live tags must not be
+	// filled in on first use
+	for _, elem := range elements {
+		elem.liveTags()
+	}
+
+	return View{heads: heads, elements: elements, resolve: o.resolve}, nil
+}
+
+// Heads returns the hashes the view is at, in sorted order.
+func (v View) Heads() []string {
+	return slices.Clone(v.heads)
+}
+
+// Get returns the value of key in the view.
+func (v View) Get(key string) Value {
+	elem, exists := v.elements[key]
+	if !exists {
+		return nil
+	}
+
+	return v.resolve(key, elem)
+}
+
+// Contains reports whether key has a value in the view.
+func (v View) Contains(key string) bool {
+	elem, exists := v.elements[key]
+
+	return exists && len(elem.liveTags()) > 0
+}
+
+// List returns the values of every key in the view.
+func (v View) List() map[string]Value {
+	result := make(map[string]Value)
+	for key, elem := range v.elements {
+		if value := v.resolve(key, elem); value != nil {
+			result[key] = value
+		}
+	}
+
+	return result
+}
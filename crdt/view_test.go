@@ -0,0 +1,178 @@
+package crdt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+func TestAt(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+
+	created := must(a.Add("title", scalar.New("draft")))
+	edited := must(a.Add("title", scalar.New("final")))
+	exchange(t, a, b)
+
+	// concurrent edits on both replicas
+	removed := must(a.Remove("title"))
+	body := must(b.Add("body", scalar.New("text")))
+	exchange(t, a, b)
+	merged := must(a.Add("title", scalar.New("again")))
+
+	tests := []struct {
+		name  string
+		heads []string
+		want  map[string]Value
+	}{
+		{name: "Empty", want: map[string]Value{}},
+		{name: "Created", heads: []string{created}, want: map[string]Value{"title": scalar.New("draft")}},
+		{name: "Edited", heads: []string{edited}, want: map[string]Value{"title": scalar.New("final")}},
+		{name: "Removed", heads: []string{removed}, want: map[string]Value{}},
+		{
+			name:  "Concurrent edit",
+			heads: []string{body},
+			want:  map[string]Value{"title": scalar.New("final"), "body": scalar.New("text")},
+		},
+		{
+			name:  "Both edits",
+			heads: []string{removed, body},
+			want:  map[string]Value{"body": scalar.New("text")},
+		},
+		{
+			name:  "Ancestor among heads",
+			heads: []string{edited, body, body},
+			want:  map[string]Value{"title": scalar.New("final"), "body": scalar.New("text")},
+		},
+		{
+			name:  "Latest",
+			heads: []string{merged},
+			want:  map[string]Value{"title": scalar.New("again"), "body": scalar.New("text")},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			view, err := a.At(tc.heads...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, view.List())
+			for key, value := range tc.want {
+				assert.Equal(t, value, view.Get(key))
+				assert.True(t, view.Contains(key))
+			}
+			if _, exists := tc.want["title"]; !exists {
+				assert.Nil(t, view.Get("title"))
+				assert.False(t, view.Contains("title"))
+			}
+		})
+	}
+
+	// the view does not follow the map
+	view := must(a.At(a.Heads()...))
+	must(a.Remove("body"))
+	assert.Equal(t, scalar.New("text"), view.Get("body"))
+	assert.Equal(t, []string{merged}, view.Heads())
+}
+
+func TestAtErrors(t *testing.T) {
+	a := must(NewORSetMap(WithReplicaID("a")))
+	b := must(NewORSetMap(WithReplicaID("b")))
+	exchange(t, a, b)
+
+	early := must(a.Add("k", scalar.New("early")))
+	exchange(t, a, b)
+	cp := must(a.Checkpoint())
+	require.NotEmpty(t, cp)
+	later := must(a.Add("k", scalar.New("later")))
+
+	_, err := a.At("unknown")
+	assert.ErrorIs(t, err, ErrUnknownMutation)
+
+	// the mutation is folded and its state only survives in the checkpoint
+	_, err = a.At(early)
+	assert.ErrorIs(t, err, ErrFolded)
+
+	view, err := a.At(cp)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Value{"k": scalar.New("early")}, view.List())
+	view, err = a.At(later)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Value{"k": scalar.New("later")}, view.List())
+
+	// a mutation of b that names the folded mutation as a parent, without
+	// the checkpoint, cannot be read
+	concurrent := must(b.Add("other", scalar.New("b")))
+	require.NoError(t, a.ImportLog(must(b.ExportLog())))
+	_, err = a.At(concurrent)
+	assert.ErrorIs(t, err, ErrFolded)
+	view, err = a.At(concurrent, later)
+	require.NoError(t, err)
+	assert.Equal(t, a.List(), view.List())
+
+	// parked mutations are not applied
+	parked := Mutation{
+		Operations: []*Operation{{Key: "k", Value: scalar.New("v"), Tags: Tags{{ReplicaID: "c", Sequence: 1}: true}}},
+		Parents:    []string{"missing"},
+		Origin:     Tag{ReplicaID: "c", Sequence: 1},
+	}
+	require.ErrorIs(t, a.ImportLog([]Mutation{parked}), ErrUnknownParent)
+	_, err = a.At(must(HashMutation(parked)))
+	assert.ErrorIs(t, err, ErrUnknownMutation)
+}
+
+func TestAtRestored(t *testing.T) {
+	store := NewMemoryStore()
+	a := must(NewORSetMap(WithReplicaID("a"), WithStore(store)))
+	early := must(a.Add("k", scalar.New("early")))
+	snapshot := a.Snapshot()
+	tail := must(a.Add("k", scalar.New("later")))
+
+	// the snapshot stands in for the mutations it covers
+	restored := must(Restore(snapshot, must(a.ExportLog())[1:], WithReplicaID("a")))
+	_, err := restored.At(early)
+	assert.ErrorIs(t, err, ErrFolded)
+	_, err = restored.At(tail)
+	assert.ErrorIs(t, err, ErrFolded)
+
+	// unless the store still holds them
+	restored = must(Restore(snapshot, nil, WithReplicaID("a"), WithStore(store)))
+	view, err := restored.At(early)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Value{"k": scalar.New("early")}, view.List())
+}
+
+func TestAtResolver(t *testing.T) {
+	o := must(NewORSetMap(WithResolver(MaxValue)))
+	require.NoError(t, o.ImportLog([]Mutation{
+		concurrentWrite("a", 1, "k", scalar.New(int64(5)), time.Time{}),
+		concurrentWrite("b", 1, "k", scalar.New(int64(1)), time.Time{}),
+	}))
+
+	view := must(o.At(o.Heads()...))
+	assert.Equal(t, scalar.New(int64(5)), view.Get("k"))
+	assert.Equal(t, o.List(), view.List())
+}
+
+func TestViewConcurrentReads(t *testing.T) {
+	o := must(NewORSetMap(WithReplicaID("a")))
+	must(o.Add("title", scalar.New("draft")))
+	view := must(o.At(o.Heads()...))
+
+	// views are shared between readers without locking
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, view.Contains("title"))
+			assert.Equal(t, scalar.New("draft"), view.Get("title"))
+			assert.Len(t, view.List(), 1)
+		}()
+	}
+	wg.Wait()
+}
@@ -13,41 +13,65 @@ type (
 	}
 )
 
-func NewPost() *Post {
-	return &Post{
-		crdt.NewORSetMap(),
+func NewPost() (*Post, error) {
+	m, err := crdt.NewORSetMap()
+	if err != nil {
+		return nil, err
 	}
+
+	return &Post{m}, nil
 }
 
-func (p *Post) SetTitle(title string) {
-	p.Add("title", scalar.New(title))
+func (p *Post) SetTitle(title string) error {
+	_, err := p.Add("title", scalar.New(title))
+	return err
 }
 
-func (p *Post) GetTitle() string {
+func (p *Post) GetTitle() (string, error) {
 	return assume[string](p.Get("title"))
 }
 
-func (p *Post) SetBody(body string) {
-	p.Add("body", scalar.New(body))
+func (p *Post) SetBody(body string) error {
+	_, err := p.Add("body", scalar.New(body))
+	return err
 }
 
-func (p *Post) GetBody() string {
+func (p *Post) GetBody() (string, error) {
 	return assume[string](p.Get("body"))
 }
 
 func main() {
-	post := NewPost()
-	post.SetTitle("Hello, World!")
+	post, err := NewPost()
+	if err != nil {
+		panic(err)
+	}
+	if err := post.SetTitle("Hello, World!"); err != nil {
+		panic(err)
+	}
 
-	fmt.Println(post.GetTitle())
-	fmt.Println(post.GetBody())
+	title, err := post.GetTitle()
+	if err != nil {
+		panic(err)
+	}
+	body, err := post.GetBody()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(title)
+	fmt.Println(body)
 }
 
-func assume[T scalar.ScalarValue](v crdt.Value) T {
-	s, ok := v.(*scalar.Scalar[T])
-	if !ok {
-		return *new(T)
+// assume returns the value as a T, the zero T if there is no value, or an
+// error if the value has another type.
+func assume[T scalar.ScalarValue](v crdt.Value) (T, error) {
+	if v == nil {
+		return *new(T), nil
+	}
+	a, err := crdt.ToAny(v)
+	if err != nil {
+		return *new(T), err
 	}
 
-	return s.Value
+	return scalar.As[T](a)
 }
@@ -0,0 +1,230 @@
+package scalar
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+// ErrTypeMismatch is returned when reading a value as a type it does not
+// have.
+var ErrTypeMismatch = errors.New("type mismatch")
+
+// Any is a scalar value whose type is only known at run time. The zero Any
+// holds no value and has type -1.
+type Any struct {
+	value any // one of the ScalarValue types, or nil
+}
+
+// NewAny returns an Any holding v.
+func NewAny[T ScalarValue](v T) Any {
+	return Any{value: v}
+}
+
+// As returns the value of a as a T, or ErrTypeMismatch if a holds a value of
+// another type.
+func As[T ScalarValue](a Any) (T, error) {
+	v, ok := a.value.(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w: value of type %d read as %T", ErrTypeMismatch, a.Type(), zero)
+	}
+
+	return v, nil
+}
+
+func (a Any) Type() Type {
+	switch a.value.(type) {
+	case string:
+		return String
+	case int64:
+		return Int64
+	case uint64:
+		return Uint64
+	case float64:
+		return Float64
+	case []byte:
+		return ByteSlice
+	case bool:
+		return Bool
+	case time.Time:
+		return Timestamp
+	case DecimalValue:
+		return Decimal
+	case UUIDValue:
+		return UUID
+	case NullValue:
+		return Null
+	default:
+		return -1
+	}
+}
+
+// String returns if the value is a string.
+func (a Any) String() (string, bool) {
+	v, ok := a.value.(string)
+
+	return v, ok
+}
+
+// Int64 returns if the value is an int64.
+func (a Any) Int64() (int64, bool) {
+	v, ok := a.value.(int64)
+
+	return v, ok
+}
+
+// Uint64 returns if the value is a uint64.
+func (a Any) Uint64() (uint64, bool) {
+	v, ok := a.value.(uint64)
+
+	return v, ok
+}
+
+// Float64 returns if the value is a float64.
+func (a Any) Float64() (float64, bool) {
+	v, ok := a.value.(float64)
+
+	return v, ok
+}
+
+// ByteSlice returns if the value is a byte slice.
+func (a Any) ByteSlice() ([]byte, bool) {
+	v, ok := a.value.([]byte)
+
+	return v, ok
+}
+
+// Bool returns if the value is a bool.
+func (a Any) Bool() (bool, bool) {
+	v, ok := a.value.(bool)
+
+	return v, ok
+}
+
+// Timestamp returns if the value is a time.
+func (a Any) Timestamp() (time.Time, bool) {
+	v, ok := a.value.(time.Time)
+
+	return v, ok
+}
+
+// Decimal returns if the value is a decimal.
+func (a Any) Decimal() (DecimalValue, bool) {
+	v, ok := a.value.(DecimalValue)
+
+	return v, ok
+}
+
+// UUID returns if the value is a UUID.
+func (a Any) UUID() (UUIDValue, bool) {
+	v, ok := a.value.(UUIDValue)
+
+	return v, ok
+}
+
+// Null returns if the value is null.
+func (a Any) Null() (NullValue, bool) {
+	v, ok := a.value.(NullValue)
+
+	return v, ok
+}
+
+// Equal reports whether a and other hold values of the same type that
+// compare equal.
+func (a Any) Equal(other Any) bool {
+	return a.Compare(other) == 0
+}
+
+// Compare returns -1 if a orders before other, 1 if it orders after and 0 if
+// they are equal. Values of different types are ordered by type. Byte slices
+// and UUIDs are ordered lexicographically, false before true, NaN before
+// every other float, and decimals by value whatever their scales.
+func (a Any) Compare(other Any) int {
+	if c := cmp.Compare(a.Type(), other.Type()); c != 0 {
+		return c
+	}
+
+	switch v := a.value.(type) {
+	case string:
+		return cmp.Compare(v, other.value.(string))
+	case int64:
+		return cmp.Compare(v, other.value.(int64))
+	case uint64:
+		return cmp.Compare(v, other.value.(uint64))
+	case float64:
+		return cmp.Compare(v, other.value.(float64))
+	case []byte:
+		return bytes.Compare(v, other.value.([]byte))
+	case bool:
+		o := other.value.(bool)
+		switch {
+		case v == o:
+			return 0
+		case o:
+			return -1
+		default:
+			return 1
+		}
+	case time.Time:
+		return v.Compare(other.value.(time.Time))
+	case DecimalValue:
+		return v.Compare(other.value.(DecimalValue))
+	case UUIDValue:
+		o := other.value.(UUIDValue)
+		return bytes.Compare(v[:], o[:])
+	default:
+		return 0
+	}
+}
+
+// Hash returns a hash of the value that is equal for equal values and stable
+// across processes.
+func (a Any) Hash() uint64 {
+	h := fnv.New64a()
+	var b []byte
+	b = binary.AppendVarint(b, int64(a.Type()))
+	switch v := a.value.(type) {
+	case string:
+		b = append(b, v...)
+	case int64:
+		b = binary.BigEndian.AppendUint64(b, uint64(v))
+	case uint64:
+		b = binary.BigEndian.AppendUint64(b, v)
+	case float64:
+		// equal floats with different bits hash alike
+		switch {
+		case math.IsNaN(v):
+			v = math.NaN()
+		case v == 0:
+			v = 0
+		}
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	case []byte:
+		b = append(b, v...)
+	case bool:
+		if v {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	case time.Time:
+		b = binary.AppendVarint(b, v.Unix())
+		b = binary.AppendUvarint(b, uint64(v.Nanosecond()))
+	case DecimalValue:
+		// equal decimals with different scales hash alike
+		n := v.normalize()
+		b = append(b, n.Scale, byte(n.Coefficient.Sign()+1))
+		b = n.Coefficient.Append(b, 16)
+	case UUIDValue:
+		b = append(b, v[:]...)
+	}
+	_, _ = h.Write(b)
+
+	return h.Sum64()
+}
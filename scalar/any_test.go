@@ -0,0 +1,176 @@
+package scalar_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAny(v any) scalar.Any {
+	switch v := v.(type) {
+	case string:
+		return scalar.NewAny(v)
+	case int64:
+		return scalar.NewAny(v)
+	case uint64:
+		return scalar.NewAny(v)
+	case float64:
+		return scalar.NewAny(v)
+	case []byte:
+		return scalar.NewAny(v)
+	case bool:
+		return scalar.NewAny(v)
+	case time.Time:
+		return scalar.NewAny(v)
+	case scalar.DecimalValue:
+		return scalar.NewAny(v)
+	case scalar.UUIDValue:
+		return scalar.NewAny(v)
+	case scalar.NullValue:
+		return scalar.NewAny(v)
+	default:
+		return scalar.Any{}
+	}
+}
+
+func TestAny_Type(t *testing.T) {
+	for _, tt := range scalarTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valueType, newAny(tt.value).Type())
+		})
+	}
+	assert.Equal(t, scalar.Type(-1), scalar.Any{}.Type())
+}
+
+func TestAs(t *testing.T) {
+	for _, tt := range scalarTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAny(tt.value)
+			var got any
+			var err error
+
+			switch tt.valueType {
+			case scalar.String:
+				got, err = scalar.As[string](a)
+			case scalar.Int64:
+				got, err = scalar.As[int64](a)
+			case scalar.Uint64:
+				got, err = scalar.As[uint64](a)
+			case scalar.Float64:
+				got, err = scalar.As[float64](a)
+			case scalar.ByteSlice:
+				got, err = scalar.As[[]byte](a)
+			case scalar.Bool:
+				got, err = scalar.As[bool](a)
+			case scalar.Timestamp:
+				got, err = scalar.As[time.Time](a)
+			case scalar.Decimal:
+				got, err = scalar.As[scalar.DecimalValue](a)
+			case scalar.UUID:
+				got, err = scalar.As[scalar.UUIDValue](a)
+			case scalar.Null:
+				got, err = scalar.As[scalar.NullValue](a)
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+		})
+	}
+}
+
+func TestAsMismatch(t *testing.T) {
+	v, err := scalar.As[string](scalar.NewAny(int64(42)))
+	assert.ErrorIs(t, err, scalar.ErrTypeMismatch)
+	assert.Empty(t, v)
+
+	_, err = scalar.As[int64](scalar.NewAny(uint64(42)))
+	assert.ErrorIs(t, err, scalar.ErrTypeMismatch)
+
+	_, err = scalar.As[bool](scalar.Any{})
+	assert.ErrorIs(t, err, scalar.ErrTypeMismatch)
+}
+
+func TestAny_Compare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b scalar.Any
+		want int
+	}{
+		{name: "Equal strings", a: scalar.NewAny("a"), b: scalar.NewAny("a"), want: 0},
+		{name: "Strings", a: scalar.NewAny("a"), b: scalar.NewAny("b"), want: -1},
+		{name: "Int64s", a: scalar.NewAny(int64(-1)), b: scalar.NewAny(int64(-2)), want: 1},
+		{name: "Uint64s", a: scalar.NewAny(uint64(1)), b: scalar.NewAny(uint64(2)), want: -1},
+		{name: "Float64s", a: scalar.NewAny(1.5), b: scalar.NewAny(-1.5), want: 1},
+		{name: "Zero and negative zero", a: scalar.NewAny(0.0), b: scalar.NewAny(math.Copysign(0, -1)), want: 0},
+		{name: "NaNs", a: scalar.NewAny(math.NaN()), b: scalar.NewAny(math.NaN()), want: 0},
+		{name: "NaN first", a: scalar.NewAny(math.NaN()), b: scalar.NewAny(math.Inf(-1)), want: -1},
+		{name: "Byte slices", a: scalar.NewAny([]byte("ab")), b: scalar.NewAny([]byte("b")), want: -1},
+		{name: "Equal byte slices", a: scalar.NewAny([]byte("ab")), b: scalar.NewAny([]byte("ab")), want: 0},
+		{name: "Bools", a: scalar.NewAny(true), b: scalar.NewAny(false), want: 1},
+		{name: "Timestamps", a: scalar.NewAny(time.Unix(1, 0)), b: scalar.NewAny(time.Unix(2, 0)), want: -1},
+		{name: "Timestamps in other locations", a: scalar.NewAny(time.Unix(1, 0)), b: scalar.NewAny(time.Unix(1, 0).UTC()), want: 0},
+		{name: "Decimals", a: scalar.NewAny(scalar.NewDecimal(-150, 2)), b: scalar.NewAny(scalar.NewDecimal(-2, 0)), want: 1},
+		{name: "Decimals of other scales", a: scalar.NewAny(scalar.NewDecimal(150, 2)), b: scalar.NewAny(scalar.NewDecimal(15, 1)), want: 0},
+		{name: "Zero decimals", a: scalar.NewAny(scalar.DecimalValue{}), b: scalar.NewAny(scalar.NewDecimal(0, 3)), want: 0},
+		{name: "UUIDs", a: scalar.NewAny(scalar.UUIDValue{1}), b: scalar.NewAny(scalar.UUIDValue{2}), want: -1},
+		{name: "Nulls", a: scalar.NewAny(scalar.NullValue{}), b: scalar.NewAny(scalar.NullValue{}), want: 0},
+		{name: "Types", a: scalar.NewAny("a"), b: scalar.NewAny(int64(0)), want: -1},
+		{name: "Same value of other type", a: scalar.NewAny(uint64(1)), b: scalar.NewAny(int64(1)), want: 1},
+		{name: "No value", a: scalar.Any{}, b: scalar.NewAny(""), want: -1},
+		{name: "No values", a: scalar.Any{}, b: scalar.Any{}, want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.a.Compare(tc.b))
+			assert.Equal(t, -tc.want, tc.b.Compare(tc.a))
+			assert.Equal(t, tc.want == 0, tc.a.Equal(tc.b))
+			if tc.want == 0 {
+				assert.Equal(t, tc.a.Hash(), tc.b.Hash())
+			}
+		})
+	}
+}
+
+func TestAny_Hash(t *testing.T) {
+	values := []scalar.Any{
+		scalar.NewAny(""),
+		scalar.NewAny("1"),
+		scalar.NewAny(int64(1)),
+		scalar.NewAny(uint64(1)),
+		scalar.NewAny(1.0),
+		scalar.NewAny([]byte("1")),
+		scalar.NewAny(true),
+		scalar.NewAny(false),
+		scalar.NewAny(time.Unix(1, 0)),
+		scalar.NewAny(scalar.NewDecimal(1, 0)),
+		scalar.NewAny(scalar.NewDecimal(1, 1)),
+		scalar.NewAny(scalar.NewDecimal(-1, 0)),
+		scalar.NewAny(scalar.UUIDValue{1}),
+		scalar.NewAny(scalar.NullValue{}),
+		{},
+	}
+	hashes := make(map[uint64]int)
+	for i, v := range values {
+		hashes[v.Hash()] = i
+	}
+	assert.Len(t, hashes, len(values), "values of different types hash differently")
+
+	// stable across processes
+	assert.Equal(t, uint64(0x3b6dba0d69908e25), scalar.NewAny("hello").Hash())
+}
+
+func TestScalar_Any(t *testing.T) {
+	a := scalar.New("hello").Any()
+	assert.True(t, a.Equal(scalar.NewAny("hello")))
+	v, ok := a.String()
+	assert.True(t, ok)
+	assert.Equal(t, "hello", v)
+	_, ok = a.Int64()
+	assert.False(t, ok)
+}
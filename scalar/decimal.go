@@ -0,0 +1,108 @@
+package scalar
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DecimalValue is the exact decimal number Coefficient×10^-Scale. A nil
+// Coefficient is zero. The scale is kept, so 1.50 and 1.5 are equal but
+// written differently.
+type DecimalValue struct {
+	Coefficient *big.Int
+	Scale       uint8
+}
+
+// NewDecimal returns the decimal coefficient×10^-scale.
+func NewDecimal(coefficient int64, scale uint8) DecimalValue {
+	return DecimalValue{Coefficient: big.NewInt(coefficient), Scale: scale}
+}
+
+// ParseDecimal parses a decimal such as "-12.50", keeping the number of digits
+// after the point as its scale.
+func ParseDecimal(s string) (DecimalValue, error) {
+	digits := strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" || len(fraction) > 255 || strings.Contains(digits, ".") && fraction == "" {
+		return DecimalValue{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	for _, c := range whole + fraction {
+		if c < '0' || c > '9' {
+			return DecimalValue{}, fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+
+	coefficient, _ := new(big.Int).SetString(whole+fraction, 10)
+	if len(digits) < len(s) {
+		coefficient.Neg(coefficient)
+	}
+
+	return DecimalValue{Coefficient: coefficient, Scale: uint8(len(fraction))}, nil
+}
+
+// String returns the decimal with Scale digits after the point.
+func (d DecimalValue) String() string {
+	c := d.coefficient()
+	digits := new(big.Int).Abs(c).String()
+	if d.Scale > 0 {
+		if n := int(d.Scale) + 1 - len(digits); n > 0 {
+			digits = strings.Repeat("0", n) + digits
+		}
+		digits = digits[:len(digits)-int(d.Scale)] + "." + digits[len(digits)-int(d.Scale):]
+	}
+	if c.Sign() < 0 {
+		return "-" + digits
+	}
+
+	return digits
+}
+
+// Compare returns -1 if d is less than other, 1 if it is greater and 0 if
+// they are equal, whatever their scales.
+func (d DecimalValue) Compare(other DecimalValue) int {
+	a, b := d.coefficient(), other.coefficient()
+	switch {
+	case d.Scale < other.Scale:
+		a = scaleUp(a, other.Scale-d.Scale)
+	case d.Scale > other.Scale:
+		b = scaleUp(b, d.Scale-other.Scale)
+	}
+
+	return a.Cmp(b)
+}
+
+// normalize returns the decimal with trailing zeros after the point removed.
+func (d DecimalValue) normalize() DecimalValue {
+	c := new(big.Int).Set(d.coefficient())
+	scale := d.Scale
+	ten, m := big.NewInt(10), new(big.Int)
+	for scale > 0 && c.Sign() != 0 {
+		q, r := new(big.Int).QuoRem(c, ten, m)
+		if r.Sign() != 0 {
+			break
+		}
+		c = q
+		scale--
+	}
+	if c.Sign() == 0 {
+		scale = 0
+	}
+
+	return DecimalValue{Coefficient: c, Scale: scale}
+}
+
+func (d DecimalValue) coefficient() *big.Int {
+	if d.Coefficient == nil {
+		return new(big.Int)
+	}
+
+	return d.Coefficient
+}
+
+// scaleUp returns c×10^n.
+func scaleUp(c *big.Int, n uint8) *big.Int {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+
+	return p.Mul(p, c)
+}
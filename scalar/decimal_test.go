@@ -0,0 +1,65 @@
+package scalar_test
+
+import (
+	"testing"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  scalar.DecimalValue
+	}{
+		{input: "0", want: scalar.NewDecimal(0, 0)},
+		{input: "12", want: scalar.NewDecimal(12, 0)},
+		{input: "-12.50", want: scalar.NewDecimal(-1250, 2)},
+		{input: "0.05", want: scalar.NewDecimal(5, 2)},
+		{input: "-0.001", want: scalar.NewDecimal(-1, 3)},
+		{input: "007.10", want: scalar.NewDecimal(710, 2)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := scalar.ParseDecimal(tc.input)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want.Scale, got.Scale)
+			assert.Zero(t, tc.want.Compare(got))
+		})
+	}
+}
+
+func TestParseDecimalInvalid(t *testing.T) {
+	for _, input := range []string{"", "-", ".5", "1.", "1.2.3", "+1", "1e3", "0x10", "1_000", " 1"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := scalar.ParseDecimal(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDecimal_String(t *testing.T) {
+	tests := []struct {
+		value scalar.DecimalValue
+		want  string
+	}{
+		{value: scalar.DecimalValue{}, want: "0"},
+		{value: scalar.NewDecimal(0, 2), want: "0.00"},
+		{value: scalar.NewDecimal(-1250, 2), want: "-12.50"},
+		{value: scalar.NewDecimal(5, 3), want: "0.005"},
+		{value: scalar.NewDecimal(-5, 1), want: "-0.5"},
+		{value: scalar.NewDecimal(42, 0), want: "42"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.value.String())
+			parsed, err := scalar.ParseDecimal(tc.want)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, parsed.String())
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package scalar
 
+import "time"
+
 type Type int
 
 const (
@@ -9,12 +11,19 @@ const (
 	Float64
 	ByteSlice
 	Bool
+	Timestamp
+	Decimal
+	UUID
+	Null
 )
 
 type ScalarValue interface {
-	string | int64 | uint64 | float64 | []byte | bool
+	string | int64 | uint64 | float64 | []byte | bool | time.Time | DecimalValue | UUIDValue | NullValue
 }
 
+// NullValue is the value of a Null scalar.
+type NullValue struct{}
+
 type Scalar[T ScalarValue] struct {
 	Value T
 }
@@ -52,6 +61,14 @@ func (s *Scalar[T]) Type() Type {
 		return ByteSlice
 	case bool:
 		return Bool
+	case time.Time:
+		return Timestamp
+	case DecimalValue:
+		return Decimal
+	case UUIDValue:
+		return UUID
+	case NullValue:
+		return Null
 	default:
 		return -1
 	}
@@ -98,3 +115,36 @@ func (s *Scalar[T]) Bool() (bool, bool) {
 
 	return v, ok
 }
+
+// Timestamp returns if Scalar Value is a time.
+func (s *Scalar[T]) Timestamp() (time.Time, bool) {
+	v, ok := any(s.Value).(time.Time)
+
+	return v, ok
+}
+
+// Decimal returns if Scalar Value is a decimal.
+func (s *Scalar[T]) Decimal() (DecimalValue, bool) {
+	v, ok := any(s.Value).(DecimalValue)
+
+	return v, ok
+}
+
+// UUID returns if Scalar Value is a UUID.
+func (s *Scalar[T]) UUID() (UUIDValue, bool) {
+	v, ok := any(s.Value).(UUIDValue)
+
+	return v, ok
+}
+
+// Null returns if Scalar Value is null.
+func (s *Scalar[T]) Null() (NullValue, bool) {
+	v, ok := any(s.Value).(NullValue)
+
+	return v, ok
+}
+
+// Any returns the Scalar Value as an Any.
+func (s *Scalar[T]) Any() Any {
+	return NewAny(s.Value)
+}
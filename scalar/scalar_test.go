@@ -1,7 +1,9 @@
 package scalar_test
 
 import (
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
 
@@ -43,6 +45,26 @@ var scalarTestCases = []struct {
 		valueType: scalar.Bool,
 		value:     true,
 	},
+	{
+		name:      "timestamp",
+		valueType: scalar.Timestamp,
+		value:     time.Date(2024, 5, 1, 12, 0, 0, 42, time.UTC),
+	},
+	{
+		name:      "decimal",
+		valueType: scalar.Decimal,
+		value:     scalar.DecimalValue{Coefficient: big.NewInt(-1250), Scale: 2},
+	},
+	{
+		name:      "uuid",
+		valueType: scalar.UUID,
+		value:     scalar.UUIDValue{0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6},
+	},
+	{
+		name:      "null",
+		valueType: scalar.Null,
+		value:     scalar.NullValue{},
+	},
 }
 
 func TestNew(t *testing.T) {
@@ -70,6 +92,18 @@ func TestNew(t *testing.T) {
 			case scalar.Bool:
 				s := scalar.New(tt.value.(bool))
 				v, ok = any(s.Value).(bool)
+			case scalar.Timestamp:
+				s := scalar.New(tt.value.(time.Time))
+				v, ok = any(s.Value).(time.Time)
+			case scalar.Decimal:
+				s := scalar.New(tt.value.(scalar.DecimalValue))
+				v, ok = any(s.Value).(scalar.DecimalValue)
+			case scalar.UUID:
+				s := scalar.New(tt.value.(scalar.UUIDValue))
+				v, ok = any(s.Value).(scalar.UUIDValue)
+			case scalar.Null:
+				s := scalar.New(tt.value.(scalar.NullValue))
+				v, ok = any(s.Value).(scalar.NullValue)
 			}
 
 			assert.True(t, ok)
@@ -115,6 +149,26 @@ func TestValue(t *testing.T) {
 				var v bool
 				ok = scalar.Value(s, &v)
 				got = v
+			case scalar.Timestamp:
+				s := scalar.New(tt.value.(time.Time))
+				var v time.Time
+				ok = scalar.Value(s, &v)
+				got = v
+			case scalar.Decimal:
+				s := scalar.New(tt.value.(scalar.DecimalValue))
+				var v scalar.DecimalValue
+				ok = scalar.Value(s, &v)
+				got = v
+			case scalar.UUID:
+				s := scalar.New(tt.value.(scalar.UUIDValue))
+				var v scalar.UUIDValue
+				ok = scalar.Value(s, &v)
+				got = v
+			case scalar.Null:
+				s := scalar.New(tt.value.(scalar.NullValue))
+				var v scalar.NullValue
+				ok = scalar.Value(s, &v)
+				got = v
 			}
 
 			assert.True(t, ok)
@@ -145,6 +199,18 @@ func TestScalar_Type(t *testing.T) {
 			case scalar.Bool:
 				s := scalar.New(tt.value.(bool))
 				assert.Equal(t, s.Type(), tt.valueType)
+			case scalar.Timestamp:
+				s := scalar.New(tt.value.(time.Time))
+				assert.Equal(t, s.Type(), tt.valueType)
+			case scalar.Decimal:
+				s := scalar.New(tt.value.(scalar.DecimalValue))
+				assert.Equal(t, s.Type(), tt.valueType)
+			case scalar.UUID:
+				s := scalar.New(tt.value.(scalar.UUIDValue))
+				assert.Equal(t, s.Type(), tt.valueType)
+			case scalar.Null:
+				s := scalar.New(tt.value.(scalar.NullValue))
+				assert.Equal(t, s.Type(), tt.valueType)
 			}
 		})
 	}
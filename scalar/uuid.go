@@ -0,0 +1,31 @@
+package scalar
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// UUIDValue is a UUID as defined by RFC 9562.
+type UUIDValue [16]byte
+
+// ParseUUID parses a UUID in its canonical form, such as
+// "f81d4fae-7dec-11d0-a765-00a0c91e6bf6", in either case.
+func ParseUUID(s string) (UUIDValue, error) {
+	var u UUIDValue
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	digits := s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUIDValue{}, fmt.Errorf("invalid UUID %q: %w", s, err)
+	}
+
+	return u, nil
+}
+
+// String returns the UUID in its canonical lower case form.
+func (u UUIDValue) String() string {
+	h := hex.EncodeToString(u[:])
+
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
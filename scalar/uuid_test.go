@@ -0,0 +1,37 @@
+package scalar_test
+
+import (
+	"testing"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUUID(t *testing.T) {
+	want := scalar.UUIDValue{0xf8, 0x1d, 0x4f, 0xae, 0x7d, 0xec, 0x11, 0xd0, 0xa7, 0x65, 0x00, 0xa0, 0xc9, 0x1e, 0x6b, 0xf6}
+
+	for _, input := range []string{"f81d4fae-7dec-11d0-a765-00a0c91e6bf6", "F81D4FAE-7DEC-11D0-A765-00A0C91E6BF6"} {
+		got, err := scalar.ParseUUID(input)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	assert.Equal(t, "f81d4fae-7dec-11d0-a765-00a0c91e6bf6", want.String())
+}
+
+func TestParseUUIDInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"f81d4fae7dec11d0a76500a0c91e6bf6",
+		"f81d4fae-7dec-11d0-a765-00a0c91e6bf",
+		"f81d4fae-7dec-11d0-a765_00a0c91e6bf6",
+		"g81d4fae-7dec-11d0-a765-00a0c91e6bf6",
+		"{f81d4fae-7dec-11d0-a765-00a0c91e6bf6}",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := scalar.ParseUUID(input)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -9,8 +9,12 @@ const (
 	Float64
 	ByteSlice
 	Bool
+	Timestamp
+	Decimal
+	UUID
+	Null
 )
 
 func (t Type) IsScalar() bool {
-	return t >= String && t <= Bool
+	return t >= String && t <= Null
 }
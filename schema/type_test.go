@@ -0,0 +1,15 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestType_IsScalar(t *testing.T) {
+	for _, typ := range []Type{String, Int64, Uint64, Float64, ByteSlice, Bool, Timestamp, Decimal, UUID, Null} {
+		assert.True(t, typ.IsScalar(), "type %d", typ)
+	}
+	assert.False(t, Type(-1).IsScalar())
+	assert.False(t, (Null + 1).IsScalar())
+}
@@ -0,0 +1,436 @@
+// Package sync reconciles two replicas of an ORSetMap over a stream.
+//
+// The peers exchange the heads of their mutation logs, along with their
+// replica IDs and versions. Each peer acknowledges the other's version, so it
+// accepts the checkpoints the other serves. Each peer then requests the heads
+// it does not know, and the parents its log is missing, by hash. The other
+// peer answers with those mutations and every ancestor that is not already an
+// ancestor of the requesting peer's heads. Parents that are still missing
+// after applying an answer are requested in a further round. The initiating
+// peer pulls first, then serves the responding peer's requests, so the
+// protocol runs in lockstep and works over synchronous transports such as
+// net.Pipe.
+package sync
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/crdt"
+)
+
+// ProtocolVersion is the version of the sync protocol, sent with the heads.
+const ProtocolVersion = 2
+
+// ErrProtocol is returned when the peer sends an unexpected message.
+var ErrProtocol = errors.New("sync protocol error")
+
+// Message kinds.
+const (
+	msgHeads byte = iota + 1
+	msgWant
+	msgMutations
+	msgVersion
+)
+
+// Limits on what a peer may send in a single message.
+const (
+	maxHashes    = 1 << 20
+	maxHashSize  = 1 << 10
+	maxMutations = 1 << 20
+)
+
+type session struct {
+	r *bufio.Reader
+	w *bufio.Writer
+	m *crdt.ORSetMap
+}
+
+func newSession(rw io.ReadWriter, m *crdt.ORSetMap) *session {
+	return &session{
+		r: bufio.NewReader(rw),
+		w: bufio.NewWriter(rw),
+		m: m,
+	}
+}
+
+// Initiate reconciles m with the peer at the other end of rw, which must run
+// Respond. When it returns without error both replicas hold the union of
+// their logs.
+func Initiate(rw io.ReadWriter, m *crdt.ORSetMap) error {
+	s := newSession(rw, m)
+
+	if err := s.writeHeads(m.Heads()); err != nil {
+		return err
+	}
+	peerHeads, err := s.readHeads()
+	if err != nil {
+		return err
+	}
+
+	if err := s.pull(peerHeads); err != nil {
+		return err
+	}
+
+	return s.serve(peerHeads)
+}
+
+// Respond reconciles m with the peer at the other end of rw, which must run
+// Initiate.
+func Respond(rw io.ReadWriter, m *crdt.ORSetMap) error {
+	s := newSession(rw, m)
+
+	peerHeads, err := s.readHeads()
+	if err != nil {
+		return err
+	}
+	if err := s.writeHeads(m.Heads()); err != nil {
+		return err
+	}
+
+	if err := s.serve(peerHeads); err != nil {
+		return err
+	}
+
+	return s.pull(peerHeads)
+}
+
+// pull requests the peer's heads and the parents missing from the local log,
+// until nothing is missing or the peer cannot provide more.
+func (s *session) pull(peerHeads []string) error {
+	requested := make(map[string]bool)
+	wants := s.unknown(peerHeads, requested)
+	wants = append(wants, s.unknown(s.m.MissingParents(), requested)...)
+	for len(wants) > 0 {
+		if err := s.writeHashes(msgWant, wants); err != nil {
+			return err
+		}
+		mutations, err := s.readMutations()
+		if err != nil {
+			return err
+		}
+		if err := s.importLog(mutations); err != nil {
+			return fmt.Errorf("failed to import mutations: %w", err)
+		}
+
+		wants = s.unknown(s.m.MissingParents(), requested)
+	}
+
+	// an empty request ends the pull
+	return s.writeHashes(msgWant, nil)
+}
+
+// importLog imports the mutations into the map. Mutations that wait for
+// parents are not an error, their parents are requested in the next round.
+func (s *session) importLog(mutations []crdt.Mutation) error {
+	err := s.m.ImportLog(mutations)
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return err
+	}
+
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		if !errors.Is(err, crdt.ErrUnknownParent) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// unknown returns the hashes that are neither in the log nor requested
+// before, and marks them as requested.
+func (s *session) unknown(hashes []string, requested map[string]bool) []string {
+	var unknown []string
+	for _, hash := range hashes {
+		if requested[hash] || s.m.Has(hash) {
+			continue
+		}
+		requested[hash] = true
+		unknown = append(unknown, hash)
+	}
+
+	return unknown
+}
+
+// serve answers the peer's requests until it sends an empty one.
+func (s *session) serve(peerHeads []string) error {
+	// the peer has every ancestor of its heads
+	covered, _ := collect(s.m, peerHeads, nil)
+
+	for {
+		wants, err := s.readHashes(msgWant)
+		if err != nil {
+			return err
+		}
+		if len(wants) == 0 {
+			return nil
+		}
+
+		hashes, mutations := collect(s.m, wants, covered)
+		for hash := range hashes {
+			covered[hash] = true
+		}
+		if err := s.writeMutations(mutations); err != nil {
+			return err
+		}
+	}
+}
+
+// collect returns the mutations in the log that are reachable from roots
+// through parent links without passing through skip, parents before
+// children, along with the set of their hashes.
+func collect(m *crdt.ORSetMap, roots []string, skip map[string]bool) (map[string]bool, []crdt.Mutation) {
+	type frame struct {
+		mu   crdt.Mutation
+		next int // index of the next parent to visit
+	}
+
+	visited := make(map[string]bool)
+	var mutations []crdt.Mutation
+	var stack []frame
+	push := func(hash string) {
+		if visited[hash] || skip[hash] {
+			return
+		}
+		mu, ok := m.Mutation(hash)
+		if !ok {
+			return
+		}
+		visited[hash] = true
+		stack = append(stack, frame{mu: mu})
+	}
+
+	for _, root := range roots {
+		push(root)
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.next < len(top.mu.Parents) {
+				top.next++
+				push(top.mu.Parents[top.next-1])
+				continue
+			}
+			mutations = append(mutations, top.mu)
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	return visited, mutations
+}
+
+// writeHeads writes the heads followed by the replica ID and version of the
+// map. The version is read after the heads, so it covers every mutation the
+// peer can request.
+func (s *session) writeHeads(heads []string) error {
+	if err := s.w.WriteByte(ProtocolVersion); err != nil {
+		return fmt.Errorf("failed to write protocol version: %w", err)
+	}
+	if err := s.writeHashes(msgHeads, heads); err != nil {
+		return err
+	}
+
+	return s.writeVersion(s.m.ReplicaID(), s.m.Version())
+}
+
+// readHeads reads the peer's heads and acknowledges its version.
+func (s *session) readHeads() ([]string, error) {
+	version, err := s.r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protocol version: %w", err)
+	}
+	if version != ProtocolVersion {
+		return nil, fmt.Errorf("%w: unsupported protocol version %d", ErrProtocol, version)
+	}
+	heads, err := s.readHashes(msgHeads)
+	if err != nil {
+		return nil, err
+	}
+	replicaID, peerVersion, err := s.readVersion()
+	if err != nil {
+		return nil, err
+	}
+	s.m.Acknowledge(replicaID, peerVersion)
+
+	return heads, nil
+}
+
+func (s *session) writeVersion(replicaID string, v crdt.Version) error {
+	b := []byte{msgVersion}
+	b = binary.AppendUvarint(b, uint64(len(replicaID)))
+	b = append(b, replicaID...)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	for replica, seq := range v {
+		b = binary.AppendUvarint(b, uint64(len(replica)))
+		b = append(b, replica...)
+		b = binary.AppendUvarint(b, seq)
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
+
+func (s *session) readVersion() (string, crdt.Version, error) {
+	got, err := s.r.ReadByte()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	if got != msgVersion {
+		return "", nil, fmt.Errorf("%w: expected message %d, got %d", ErrProtocol, msgVersion, got)
+	}
+	replicaID, err := s.readString()
+	if err != nil {
+		return "", nil, err
+	}
+	n, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	if n > maxHashes {
+		return "", nil, fmt.Errorf("%w: version with %d entries", ErrProtocol, n)
+	}
+
+	v := make(crdt.Version, n)
+	for i := uint64(0); i < n; i++ {
+		replica, err := s.readString()
+		if err != nil {
+			return "", nil, err
+		}
+		seq, err := binary.ReadUvarint(s.r)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read version: %w", err)
+		}
+		v[replica] = seq
+	}
+
+	return replicaID, v, nil
+}
+
+// readString reads a length-prefixed string of at most maxHashSize bytes.
+func (s *session) readString() (string, error) {
+	size, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read string: %w", err)
+	}
+	if size > maxHashSize {
+		return "", fmt.Errorf("%w: string of %d bytes", ErrProtocol, size)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(s.r, b); err != nil {
+		return "", fmt.Errorf("failed to read string: %w", err)
+	}
+
+	return string(b), nil
+}
+
+func (s *session) writeHashes(kind byte, hashes []string) error {
+	b := []byte{kind}
+	b = binary.AppendUvarint(b, uint64(len(hashes)))
+	for _, hash := range hashes {
+		b = binary.AppendUvarint(b, uint64(len(hash)))
+		b = append(b, hash...)
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
+
+func (s *session) readHashes(kind byte) ([]string, error) {
+	n, err := s.readHeader(kind, maxHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for i := uint64(0); i < n; i++ {
+		size, err := binary.ReadUvarint(s.r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hash: %w", err)
+		}
+		if size > maxHashSize {
+			return nil, fmt.Errorf("%w: hash of %d bytes", ErrProtocol, size)
+		}
+		hash := make([]byte, size)
+		if _, err := io.ReadFull(s.r, hash); err != nil {
+			return nil, fmt.Errorf("failed to read hash: %w", err)
+		}
+		hashes = append(hashes, string(hash))
+	}
+
+	return hashes, nil
+}
+
+func (s *session) writeMutations(mutations []crdt.Mutation) error {
+	b := []byte{msgMutations}
+	b = binary.AppendUvarint(b, uint64(len(mutations)))
+	if _, err := s.w.Write(b); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	enc := crdt.NewEncoder(s.w)
+	for _, mu := range mutations {
+		if err := enc.Encode(mu); err != nil {
+			return err
+		}
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+
+	return nil
+}
+
+func (s *session) readMutations() ([]crdt.Mutation, error) {
+	n, err := s.readHeader(msgMutations, maxMutations)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutations []crdt.Mutation
+	dec := crdt.NewDecoder(s.r)
+	for i := uint64(0); i < n; i++ {
+		mu, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		mutations = append(mutations, mu)
+	}
+
+	return mutations, nil
+}
+
+// readHeader reads the kind and element count of a message.
+func (s *session) readHeader(kind byte, limit uint64) (uint64, error) {
+	got, err := s.r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read message: %w", err)
+	}
+	if got != kind {
+		return 0, fmt.Errorf("%w: expected message %d, got %d", ErrProtocol, kind, got)
+	}
+	n, err := binary.ReadUvarint(s.r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read message: %w", err)
+	}
+	if n > limit {
+		return 0, fmt.Errorf("%w: message with %d elements", ErrProtocol, n)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,285 @@
+package sync
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/crdt"
+	"github.com/df8f7892-ba65-4f95-ba24-7918c2a94a0b/prototype0/scalar"
+)
+
+// newORSetMap creates an ORSetMap with the given replica ID.
+func newORSetMap(t *testing.T, replicaID string) *crdt.ORSetMap {
+	t.Helper()
+
+	m, err := crdt.NewORSetMap(crdt.WithReplicaID(replicaID))
+	require.NoError(t, err)
+
+	return m
+}
+
+// syncPipe reconciles a and b over an in-memory pipe.
+func syncPipe(t *testing.T, a, b *crdt.ORSetMap) {
+	t.Helper()
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Respond(c2, b)
+	}()
+	require.NoError(t, Initiate(c1, a))
+	require.NoError(t, <-errc)
+}
+
+// copyLog imports the first n mutations of src's log into dst.
+func copyLog(t *testing.T, dst, src *crdt.ORSetMap, n int) {
+	t.Helper()
+
+	log, err := src.ExportLog()
+	require.NoError(t, err)
+	require.NoError(t, dst.ImportLog(log[:n]))
+}
+
+// hashMutation returns the hash of the mutation.
+func hashMutation(t *testing.T, mu crdt.Mutation) string {
+	t.Helper()
+
+	hash, err := crdt.HashMutation(mu)
+	require.NoError(t, err)
+
+	return hash
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name      string
+		mutations func(t *testing.T, a, b *crdt.ORSetMap)
+		expected  map[string]crdt.Value
+	}{
+		{
+			name:      "Both empty",
+			mutations: func(t *testing.T, a, b *crdt.ORSetMap) {},
+			expected:  map[string]crdt.Value{},
+		},
+		{
+			name: "Initiator ahead",
+			mutations: func(t *testing.T, a, b *crdt.ORSetMap) {
+				a.Add("title", scalar.New("Hello"))
+				a.Add("body", scalar.New("World"))
+			},
+			expected: map[string]crdt.Value{
+				"title": scalar.New("Hello"),
+				"body":  scalar.New("World"),
+			},
+		},
+		{
+			name: "Responder ahead",
+			mutations: func(t *testing.T, a, b *crdt.ORSetMap) {
+				b.Add("title", scalar.New("Hello"))
+				b.Remove("title")
+				b.Add("body", scalar.New("World"))
+			},
+			expected: map[string]crdt.Value{
+				"body": scalar.New("World"),
+			},
+		},
+		{
+			name: "Divergent histories",
+			mutations: func(t *testing.T, a, b *crdt.ORSetMap) {
+				a.Add("title", scalar.New("Hello"))
+				a.Add("body", scalar.New("World"))
+				copyLog(t, b, a, 2)
+				for i := 0; i < 10; i++ {
+					a.Add("a"+strconv.Itoa(i), scalar.New(int64(i)))
+					b.Add("b"+strconv.Itoa(i), scalar.New(int64(i)))
+				}
+				a.Add("title", scalar.New("from a"))
+				b.Remove("body")
+			},
+			expected: func() map[string]crdt.Value {
+				expected := map[string]crdt.Value{
+					"title": scalar.New("from a"),
+				}
+				for i := 0; i < 10; i++ {
+					expected["a"+strconv.Itoa(i)] = scalar.New(int64(i))
+					expected["b"+strconv.Itoa(i)] = scalar.New(int64(i))
+				}
+				return expected
+			}(),
+		},
+		{
+			name: "Partially overlapping logs",
+			mutations: func(t *testing.T, a, b *crdt.ORSetMap) {
+				for i := 0; i < 20; i++ {
+					a.Add("key", scalar.New(int64(i)))
+				}
+				copyLog(t, b, a, 12)
+				b.Add("other", scalar.New("b"))
+			},
+			expected: map[string]crdt.Value{
+				"key":   scalar.New(int64(19)),
+				"other": scalar.New("b"),
+			},
+		},
+		{
+			name: "Already in sync",
+			mutations: func(t *testing.T, a, b *crdt.ORSetMap) {
+				a.Add("title", scalar.New("Hello"))
+				copyLog(t, b, a, 1)
+			},
+			expected: map[string]crdt.Value{
+				"title": scalar.New("Hello"),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newORSetMap(t, "a")
+			b := newORSetMap(t, "b")
+			tc.mutations(t, a, b)
+
+			syncPipe(t, a, b)
+
+			for _, replica := range []*crdt.ORSetMap{a, b} {
+				assert.Equal(t, tc.expected, replica.List(), "replica "+replica.ReplicaID())
+				assert.NotEqual(t, crdt.Partial, replica.State(), "replica "+replica.ReplicaID())
+			}
+			assert.Equal(t, a.Heads(), b.Heads())
+			assert.Equal(t, crdt.Equal, crdt.Compare(a.Version(), b.Version()))
+		})
+	}
+}
+
+func TestSyncFetchesMissingParents(t *testing.T) {
+	a := newORSetMap(t, "a")
+	for i := 0; i < 5; i++ {
+		a.Add("key", scalar.New(int64(i)))
+	}
+	log, err := a.ExportLog()
+	require.NoError(t, err)
+
+	// b only knows the last mutation, which is waiting for its ancestors
+	b := newORSetMap(t, "b")
+	require.ErrorIs(t, b.ImportLog(log[4:]), crdt.ErrUnknownParent)
+	require.Equal(t, crdt.Partial, b.State())
+	require.Empty(t, b.Heads())
+
+	syncPipe(t, b, a)
+
+	assert.Equal(t, crdt.Complete, b.State())
+	assert.Empty(t, b.MissingParents())
+	assert.Equal(t, a.List(), b.List())
+	assert.Equal(t, a.Heads(), b.Heads())
+}
+
+func TestSyncPeerMissingParents(t *testing.T) {
+	a := newORSetMap(t, "a")
+	for i := 0; i < 3; i++ {
+		a.Add("key", scalar.New(int64(i)))
+	}
+	log, err := a.ExportLog()
+	require.NoError(t, err)
+
+	// neither peer has the parent of b's pending mutation
+	b := newORSetMap(t, "b")
+	require.ErrorIs(t, b.ImportLog(log[2:]), crdt.ErrUnknownParent)
+	c := newORSetMap(t, "c")
+	c.Add("other", scalar.New("c"))
+
+	syncPipe(t, b, c)
+
+	assert.Equal(t, crdt.Partial, b.State())
+	assert.Equal(t, []string{hashMutation(t, log[1])}, b.MissingParents())
+	assert.Equal(t, c.List(), b.List())
+}
+
+func TestSyncThreeReplicas(t *testing.T) {
+	a := newORSetMap(t, "a")
+	b := newORSetMap(t, "b")
+	c := newORSetMap(t, "c")
+	a.Add("a", scalar.New("a"))
+	b.Add("b", scalar.New("b"))
+	c.Add("c", scalar.New("c"))
+
+	syncPipe(t, a, b)
+	c.Add("title", scalar.New("c"))
+	syncPipe(t, b, c)
+	syncPipe(t, c, a)
+
+	for _, replica := range []*crdt.ORSetMap{a, b, c} {
+		assert.Equal(t, map[string]crdt.Value{
+			"a":     scalar.New("a"),
+			"b":     scalar.New("b"),
+			"c":     scalar.New("c"),
+			"title": scalar.New("c"),
+		}, replica.List(), "replica "+replica.ReplicaID())
+	}
+	assert.Equal(t, a.Heads(), b.Heads())
+	assert.Equal(t, a.Heads(), c.Heads())
+}
+
+func TestSyncCheckpoint(t *testing.T) {
+	a := newORSetMap(t, "a")
+	for i := 0; i < 5; i++ {
+		a.Add("key", scalar.New(int64(i)))
+	}
+	b := newORSetMap(t, "b")
+	copyLog(t, b, a, 2)
+
+	// alone, a can fold all of its mutations
+	hash, err := a.Checkpoint()
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+	a.Add("after", scalar.New("a"))
+
+	c := newORSetMap(t, "c")
+	for _, replica := range []*crdt.ORSetMap{b, c} {
+		syncPipe(t, replica, a)
+
+		assert.Equal(t, crdt.Complete, replica.State(), "replica "+replica.ReplicaID())
+		assert.Equal(t, a.List(), replica.List(), "replica "+replica.ReplicaID())
+		assert.Equal(t, a.Heads(), replica.Heads(), "replica "+replica.ReplicaID())
+	}
+}
+
+func TestCollect(t *testing.T) {
+	a := newORSetMap(t, "a")
+	for i := 0; i < 10; i++ {
+		a.Add("key", scalar.New(int64(i)))
+	}
+	log, err := a.ExportLog()
+	require.NoError(t, err)
+
+	// a peer whose head is the fourth mutation only misses the last six
+	peerHeads := []string{hashMutation(t, log[3])}
+	covered, _ := collect(a, peerHeads, nil)
+	assert.Len(t, covered, 4)
+
+	_, missing := collect(a, a.Heads(), covered)
+	assert.Equal(t, log[4:], missing)
+
+	// unknown hashes are skipped
+	hashes, mutations := collect(a, []string{"unknown"}, nil)
+	assert.Empty(t, hashes)
+	assert.Empty(t, mutations)
+}
+
+func TestSyncProtocolError(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	go func() {
+		_, _ = c2.Write([]byte{ProtocolVersion, msgWant, 0})
+	}()
+	err := Respond(c1, newORSetMap(t, ""))
+	assert.ErrorIs(t, err, ErrProtocol)
+}